@@ -0,0 +1,167 @@
+// Copyright 2015 Igor Dolzhikov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// fingerprintLength is a number of hex digits of content hash in fingerprinted names
+const fingerprintLength = 8
+
+// Assets serves files at fingerprinted URLs, e.g. "/static/app.3f9a1c0b.js",
+// which may be cached forever since their content never changes
+type Assets struct {
+	files  *FileServer
+	prefix string
+	// urls maps logical names to fingerprinted names
+	urls map[string]string
+	// names maps fingerprinted names to logical names
+	names map[string]string
+}
+
+// ServeAssets computes content hashes of files in the file system and serves them
+// at fingerprinted URLs with immutable caching. The path must end with catch-all
+// parameter like in ServeFiles. Requests for stale fingerprints are redirected
+// to the current ones, files requested by logical names are served as usual.
+func (r *Router) ServeAssets(path string, fsys fs.FS) (*Assets, error) {
+	parts, _ := split(path)
+	if len(parts) == 0 || len(parts[len(parts)-1]) < 2 || parts[len(parts)-1][0:1] != asterisk {
+		panic("router: path must end with catch-all parameter like /*filepath in path '" + path + "'")
+	}
+	assets := &Assets{
+		files: &FileServer{
+			FS:            fsys,
+			Param:         parts[len(parts)-1],
+			Index:         []string{"index.html"},
+			Precompressed: true,
+			router:        r,
+		},
+		prefix: "/" + join(parts[:len(parts)-1]) + "/",
+		urls:   make(map[string]string),
+		names:  make(map[string]string),
+	}
+	if assets.prefix == "//" {
+		assets.prefix = "/"
+	}
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		// precompressed siblings are served with the original file
+		if strings.HasSuffix(name, ".gz") {
+			if _, err := fs.Stat(fsys, strings.TrimSuffix(name, ".gz")); err == nil {
+				return nil
+			}
+		}
+		hash, err := hashFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		fingerprinted := fingerprint(name, hash)
+		assets.urls[name] = fingerprinted
+		assets.names[fingerprinted] = name
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.GET(path, assets.Serve)
+	r.HEAD(path, assets.Serve)
+
+	return assets, nil
+}
+
+// URL returns fingerprinted URL of the file with logical name, e.g. "js/app.js",
+// or URL of the file itself if it is unknown
+func (a *Assets) URL(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	if fingerprinted, ok := a.urls[name]; ok {
+		return a.prefix + fingerprinted
+	}
+	return a.prefix + name
+}
+
+// Serve is a handler which serves fingerprinted files
+func (a *Assets) Serve(c *Control) {
+	name, ok := cleanFilePath(c.Param(bareParamKey(a.files.Param)))
+	if !ok {
+		c.Code(http.StatusBadRequest).Body(http.StatusText(http.StatusBadRequest))
+		return
+	}
+	if logical, ok := a.names[name]; ok {
+		if info, err := fs.Stat(a.files.FS, logical); err == nil && !info.IsDir() {
+			c.Writer.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+			a.files.serveFile(c, logical, info)
+			return
+		}
+		a.files.notFound(c)
+		return
+	}
+	// real files which look like fingerprinted, e.g. "deadbeef.js", are served as is
+	if _, err := fs.Stat(a.files.FS, name); err == nil {
+		a.files.serveName(c, name)
+		return
+	}
+	if logical, ok := stripFingerprint(name); ok {
+		if current, ok := a.urls[logical]; ok {
+			http.Redirect(c.Writer, c.Request, a.prefix+current, http.StatusFound)
+			return
+		}
+	}
+	a.files.serveName(c, name)
+}
+
+// hashFile returns hex encoded content hash of the file
+func hashFile(fsys fs.FS, name string) (string, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:fingerprintLength], nil
+}
+
+// fingerprint inserts the hash before extension of the file name
+func fingerprint(name, hash string) string {
+	ext := path.Ext(name)
+	if ext == path.Base(name) {
+		ext = ""
+	}
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// stripFingerprint returns logical name of the fingerprinted name
+func stripFingerprint(name string) (string, bool) {
+	ext := path.Ext(name)
+	if ext == path.Base(name) {
+		ext = ""
+	}
+	base := strings.TrimSuffix(name, ext)
+	hash := path.Ext(base)
+	if len(hash) != fingerprintLength+1 {
+		// name without extension, the hash is the last extension
+		if ext == "" || len(ext) != fingerprintLength+1 {
+			return "", false
+		}
+		hash, ext, base = ext, "", base+ext
+	}
+	for _, c := range hash[1:] {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return "", false
+		}
+	}
+	return strings.TrimSuffix(base, hash) + ext, true
+}
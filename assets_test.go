@@ -0,0 +1,111 @@
+package router
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRouterServeAssets(t *testing.T) {
+	r := New()
+	assets, err := r.ServeAssets("/static/*filepath", testFS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := assets.URL("css/app.css")
+	if !strings.HasPrefix(url, "/static/css/app.") || !strings.HasSuffix(url, ".css") || len(url) != len("/static/css/app..css")+fingerprintLength {
+		t.Fatal("Unexpected fingerprinted URL", url)
+	}
+	if assets.URL("/unknown.css") != "/static/unknown.css" {
+		t.Error("Expected", "/static/unknown.css", "got", assets.URL("/unknown.css"))
+	}
+	if _, ok := assets.urls["js/app.js.gz"]; ok {
+		t.Error("Unexpected fingerprint of precompressed sibling")
+	}
+
+	trw := testRequest(r, "GET", url, nil)
+	if trw.Body.String() != "body { color: red; }" {
+		t.Error("Expected", "body { color: red; }", "got", trw.Body.String())
+	}
+	if trw.Header().Get("Cache-Control") != "public, max-age=31536000, immutable" {
+		t.Error("Expected immutable caching, got", trw.Header().Get("Cache-Control"))
+	}
+	trw = testRequest(r, "GET", assets.URL("js/app.js"), map[string]string{"Accept-Encoding": "gzip"})
+	if trw.Body.String() != "gzipped" {
+		t.Error("Expected", "gzipped", "got", trw.Body.String())
+	}
+
+	trw = testRequest(r, "GET", "/static/css/app.0badc0de.css", nil)
+	if trw.Code != http.StatusFound || trw.Header().Get("Location") != url {
+		t.Error("Expected redirect to", url, "got", trw.Code, trw.Header().Get("Location"))
+	}
+	trw = testRequest(r, "GET", "/static/css/app.css", nil)
+	if trw.Body.String() != "body { color: red; }" || trw.Header().Get("Cache-Control") != "" {
+		t.Error("Expected file by logical name without immutable caching")
+	}
+	if trw = testRequest(r, "GET", "/static/css/missing.0badc0de.css", nil); trw.Code != http.StatusNotFound {
+		t.Error("Expected", http.StatusNotFound, "got", trw.Code)
+	}
+}
+
+func TestRouterServeAssetsFingerprintLikeNames(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app.js":           {Data: []byte("app")},
+		"app.deadbeef.js":  {Data: []byte("beef")},
+		"lib/app":          {Data: []byte("lib")},
+		"lib/app.cafebabe": {Data: []byte("babe")},
+		"main.js":          {Data: []byte("main")},
+	}
+	r := New()
+	assets, err := r.ServeAssets("/static/*filepath", fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]string{"/static/app.deadbeef.js": "beef", "/static/lib/app.cafebabe": "babe"} {
+		trw := testRequest(r, "GET", name, nil)
+		if trw.Code != http.StatusOK || trw.Body.String() != expected || trw.Header().Get("Cache-Control") != "" {
+			t.Error("Expected", expected, "got", trw.Code, trw.Body.String(), trw.Header().Get("Cache-Control"))
+		}
+	}
+
+	// fingerprinted file removed after start
+	url := assets.URL("main.js")
+	delete(fsys, "main.js")
+	if trw := testRequest(r, "GET", url, nil); trw.Code != http.StatusNotFound || trw.Header().Get("Cache-Control") != "" {
+		t.Error("Expected", http.StatusNotFound, "without caching, got", trw.Code, trw.Header().Get("Cache-Control"))
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	names := map[string]string{
+		"app.js":         "app.0badc0de.js",
+		"js/app.min.js":  "js/app.min.0badc0de.js",
+		"LICENSE":        "LICENSE.0badc0de",
+		"dir.v1/LICENSE": "dir.v1/LICENSE.0badc0de",
+	}
+	for name, expected := range names {
+		fingerprinted := fingerprint(name, "0badc0de")
+		if fingerprinted != expected {
+			t.Error("Expected", expected, "got", fingerprinted)
+		}
+		if logical, ok := stripFingerprint(fingerprinted); !ok || logical != name {
+			t.Error("Expected", name, "got", logical)
+		}
+	}
+	if _, ok := stripFingerprint("js/app.min.js"); ok {
+		t.Error("Unexpected fingerprint in js/app.min.js")
+	}
+}
+
+func TestRouterServeAssetsSources(t *testing.T) {
+	r := New()
+	r.Sources = []Source{SourceQuery}
+	if _, err := r.ServeAssets("/static/*filepath", testFS); err != nil {
+		t.Fatal(err)
+	}
+	if trw := testRequest(r, "GET", "/static/css/app.css?filepath=js/app.js", nil); trw.Code != http.StatusOK ||
+		trw.Body.String() != "body { color: red; }" {
+		t.Error("Expected file app.css, got", trw.Code, trw.Header().Get("Location"))
+	}
+}
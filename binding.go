@@ -0,0 +1,322 @@
+// Copyright 2015 Igor Dolzhikov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Locations of bound values, they are used as tags of struct fields
+// and as location types of errors
+const (
+	BindPath   = "path"
+	BindQuery  = "query"
+	BindHeader = "header"
+	BindCookie = "cookie"
+	BindForm   = "form"
+	BindBody   = "body"
+)
+
+// MIMEXML - "Content-type" for XML
+const MIMEXML = "application/xml"
+
+// mimeMultipartForm is a content type of multipart forms
+const mimeMultipartForm = "multipart/form-data"
+
+// bindTags are tags of struct fields which are bound from request values
+var bindTags = []string{BindPath, BindQuery, BindHeader, BindCookie, BindForm}
+
+// ErrUnsupportedMediaType is returned by Bind if there is no decoder for the request body
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// BindError reports a problem with a value of a field
+type BindError struct {
+	// Field is a name of the value in the location, e.g. "page" or "user.email"
+	Field string
+	// Location is one of BindPath, BindQuery, BindHeader, BindCookie, BindForm or BindBody
+	Location string
+	// Value is a value which was not bound, it is empty for the body
+	// because decoders do not report the rejected value
+	Value string
+	// Rule is a name of the failed validation rule, it is empty for binding errors
+	Rule string
+	Err  error
+}
+
+func (e *BindError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("invalid %s: %v", e.Location, e.Err)
+	}
+	return fmt.Sprintf("invalid %s value of %q: %v", e.Location, e.Field, e.Err)
+}
+
+// BindErrors contains all problems found by Bind
+type BindErrors []*BindError
+
+func (e BindErrors) Error() string {
+	messages := make([]string, len(e))
+	for idx, err := range e {
+		messages[idx] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Errors converts binding errors into error reports of ErrorHeader
+func (e BindErrors) Errors() []Error {
+	errs := make([]Error, len(e))
+	for idx, err := range e {
+		reason := "invalidParameter"
+		switch {
+		case err.Rule == "required":
+			reason = "required"
+		case err.Rule != "":
+			reason = "invalid"
+		}
+		errs[idx] = Error{
+			Domain:       "global",
+			Reason:       reason,
+			Message:      err.Error(),
+			Location:     err.Field,
+			LocationType: err.Location,
+		}
+	}
+	return errs
+}
+
+// ErrorHeader converts binding errors into the error envelope with "400 Bad Request" code,
+// or "415 Unsupported Media Type" if the body can not be decoded
+func (e BindErrors) ErrorHeader() ErrorHeader {
+	code := http.StatusBadRequest
+	for _, err := range e {
+		if errors.Is(err.Err, ErrUnsupportedMediaType) {
+			code = http.StatusUnsupportedMediaType
+		}
+	}
+	return ErrorHeader{
+		Code:    uint16(code),
+		Message: http.StatusText(code),
+		Errors:  e.Errors(),
+	}
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// Bind fills the structure pointed by v from the request. The body is decoded
+// according to its Content-Type (JSON, XML or form), then fields are filled
+// from path parameters, query, headers, cookies and form values by tags:
+//
+//	type Request struct {
+//		ID     int64     `path:"id"`
+//		Page   int       `query:"page"`
+//		Tenant string    `header:"X-Tenant"`
+//		Since  time.Time `query:"since"`
+//		Name   string    `form:"name" json:"name"`
+//	}
+//
+// Bound values are checked by rules of "validate" tags (see Validate).
+// Returned error is BindErrors if any of values is not valid, in this case
+// the error code "400 Bad Request" ("415 Unsupported Media Type" for unknown
+// content type of the body) and errors of fields are set in the Control,
+// so the handler may just render them by Body(nil).
+func (c *Control) Bind(v interface{}) error {
+	c.checkReleased()
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return errors.New("router: Bind requires a non-nil pointer to a structure")
+	}
+	var errs BindErrors
+	if err := c.bindBody(v); err != nil {
+		errs = append(errs, err)
+	}
+	errs = c.bindFields(value.Elem(), errs)
+	if len(errs) == 0 {
+		errs = validateStruct(value.Elem(), "", nil)
+	}
+	if len(errs) > 0 {
+		header := errs.ErrorHeader()
+		c.Code(int(header.Code)).SetError(header.Code, header.Message).AddError(header.Errors...)
+		return errs
+	}
+
+	return nil
+}
+
+// bindBody decodes the request body by its content type
+func (c *Control) bindBody(v interface{}) *BindError {
+	req := c.Request
+	if req.Body == nil || req.Body == http.NoBody || req.Method == "GET" || req.Method == "HEAD" {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	var err error
+	switch {
+	case mediaType == MIMEJSON || strings.HasSuffix(mediaType, "+json"):
+		err = json.NewDecoder(req.Body).Decode(v)
+	case mediaType == MIMEXML || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		err = xml.NewDecoder(req.Body).Decode(v)
+	case mediaType == MIMEForm || mediaType == mimeMultipartForm:
+		if req.PostForm == nil {
+			err = req.ParseMultipartForm(defaultMaxMemory)
+			if err == http.ErrNotMultipart {
+				err = nil
+			}
+		}
+	case mediaType == "" && req.ContentLength == 0:
+		return nil
+	default:
+		err = ErrUnsupportedMediaType
+	}
+	if err == nil || err == io.EOF {
+		return nil
+	}
+	if e, ok := err.(*json.UnmarshalTypeError); ok {
+		return &BindError{Field: e.Field, Location: BindBody, Err: fmt.Errorf("expected %s, got %s", e.Type, e.Value)}
+	}
+	return &BindError{Location: BindBody, Err: err}
+}
+
+// bindFields sets tagged fields of the structure, nested structures are processed recursively
+func (c *Control) bindFields(value reflect.Value, errs BindErrors) BindErrors {
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		tagged := false
+		for _, location := range bindTags {
+			name, ok := field.Tag.Lookup(location)
+			if !ok || name == "-" {
+				continue
+			}
+			tagged = true
+			values := c.bindValues(location, name)
+			if values == nil {
+				continue
+			}
+			if err := setField(value.Field(i), values); err != nil {
+				errs = append(errs, &BindError{Field: name, Location: location, Value: values[0], Err: err})
+			}
+			break
+		}
+		if tagged {
+			continue
+		}
+		// nested structures may contain tagged fields
+		fv := value.Field(i)
+		if fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct && field.Anonymous {
+			if fv.IsNil() {
+				if !fv.CanSet() {
+					continue
+				}
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct && fv.Type() != timeType && !reflect.PtrTo(fv.Type()).Implements(textUnmarshalerType) {
+			errs = c.bindFields(fv, errs)
+		}
+	}
+
+	return errs
+}
+
+// bindValues returns values of the name from the location or nil if they are absent
+func (c *Control) bindValues(location, name string) []string {
+	switch location {
+	case BindPath:
+		if value, ok := c.Params().Get(name); ok {
+			return []string{value}
+		}
+		return nil
+	case BindQuery:
+		return c.lookup(SourceQuery, name)
+	case BindHeader:
+		return c.lookup(SourceHeader, name)
+	case BindCookie:
+		return c.lookup(SourceCookie, name)
+	case BindForm:
+		return c.lookup(SourceForm, name)
+	}
+	return nil
+}
+
+// setField converts the values into type of the field
+func setField(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		return setField(field.Elem(), values)
+	}
+	if field.CanAddr() {
+		if u, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(values[0]))
+		}
+	}
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(values[0])
+		if err == nil {
+			field.SetInt(int64(d))
+		}
+		return err
+	}
+	switch field.Kind() {
+	case reflect.Slice:
+		if field.Type().Elem().Kind() == reflect.Uint8 {
+			field.SetBytes([]byte(values[0]))
+			return nil
+		}
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for idx, value := range values {
+			if err := setField(slice.Index(idx), []string{value}); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	case reflect.String:
+		field.SetString(values[0])
+	case reflect.Bool:
+		b, err := strconv.ParseBool(values[0])
+		if err != nil {
+			return errors.New("expected boolean")
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(values[0], 10, field.Type().Bits())
+		if err != nil {
+			return errors.New("expected integer")
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(values[0], 10, field.Type().Bits())
+		if err != nil {
+			return errors.New("expected unsigned integer")
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(values[0], field.Type().Bits())
+		if err != nil {
+			return errors.New("expected number")
+		}
+		field.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+
+	return nil
+}
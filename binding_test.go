@@ -0,0 +1,135 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testPage struct {
+	Page  int  `query:"page"`
+	Limit *int `query:"limit"`
+}
+
+type testBinding struct {
+	testPage
+	ID      int64         `path:"id"`
+	Tenant  string        `header:"X-Tenant"`
+	Session string        `cookie:"session"`
+	Tags    []string      `query:"tag"`
+	Since   time.Time     `query:"since"`
+	Timeout time.Duration `query:"timeout"`
+	Addr    netip.Addr    `query:"addr"`
+	Name    string        `form:"name" json:"name"`
+	Email   string        `json:"email"`
+	Age     int           `json:"age"`
+}
+
+func testBind(method, path, contentType, body string, v interface{}) error {
+	var err error
+	r := New()
+	r.Handle(method, "/users/:id", func(c *Control) {
+		err = c.Bind(v)
+	})
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("X-Tenant", "acme")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "s1"})
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	return err
+}
+
+func TestControlBind(t *testing.T) {
+	var v testBinding
+	err := testBind("POST", "/users/42?page=2&limit=10&tag=a&tag=b&since=2015-01-02T03:04:05Z&timeout=1m&addr=10.0.0.1",
+		MIMEJSON, `{"name": "John", "email": "john@example.com", "age": 32}`, &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.ID != 42 || v.Page != 2 || v.Limit == nil || *v.Limit != 10 {
+		t.Error("Unexpected path or query values", v.ID, v.Page, v.Limit)
+	}
+	if v.Tenant != "acme" || v.Session != "s1" || strings.Join(v.Tags, ",") != "a,b" {
+		t.Error("Unexpected header, cookie or slice values", v.Tenant, v.Session, v.Tags)
+	}
+	if !v.Since.Equal(time.Date(2015, 1, 2, 3, 4, 5, 0, time.UTC)) || v.Timeout != time.Minute || v.Addr.String() != "10.0.0.1" {
+		t.Error("Unexpected time or text values", v.Since, v.Timeout, v.Addr)
+	}
+	if v.Name != "John" || v.Email != "john@example.com" || v.Age != 32 {
+		t.Error("Unexpected body values", v.Name, v.Email, v.Age)
+	}
+
+	v = testBinding{}
+	if err := testBind("PUT", "/users/1", "application/x-www-form-urlencoded", "name=Bart", &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Name != "Bart" || v.ID != 1 {
+		t.Error("Expected", "Bart 1", "got", v.Name, v.ID)
+	}
+
+	v = testBinding{}
+	xmlBody := "<testBinding><Email>lisa@example.com</Email></testBinding>"
+	if err := testBind("POST", "/users/1", "text/xml; charset=utf-8", xmlBody, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Email != "lisa@example.com" {
+		t.Error("Expected", "lisa@example.com", "got", v.Email)
+	}
+}
+
+func TestControlBindErrors(t *testing.T) {
+	var v testBinding
+	err := testBind("POST", "/users/abc?page=x&since=yesterday", MIMEJSON, `{"age": "old"}`, &v)
+	errs, ok := err.(BindErrors)
+	if !ok {
+		t.Fatal("Expected BindErrors, got", err)
+	}
+	if len(errs) != 4 {
+		t.Fatal("Expected", 4, "got", len(errs), errs)
+	}
+	if errs[0].Location != BindBody || errs[0].Field != "age" || errs[0].Value != "" {
+		t.Error("Expected", "body age", "got", errs[0].Location, errs[0].Field, errs[0].Value)
+	}
+	if errs[0].Err.Error() != "expected int, got string" {
+		t.Error("Expected", "expected int, got string", "got", errs[0].Err)
+	}
+	header := errs.ErrorHeader()
+	if header.Code != http.StatusBadRequest || len(header.Errors) != 4 {
+		t.Error("Unexpected error header", header)
+	}
+	if header.Errors[1].Location != "page" || header.Errors[1].LocationType != BindQuery {
+		t.Error("Expected", "page query", "got", header.Errors[1].Location, header.Errors[1].LocationType)
+	}
+	if header.Errors[2].Location != "id" || header.Errors[2].LocationType != BindPath {
+		t.Error("Expected", "id path", "got", header.Errors[2].Location, header.Errors[2].LocationType)
+	}
+
+	err = testBind("POST", "/users/1", "text/csv", "a,b", &v)
+	if errs, ok := err.(BindErrors); !ok || errs[0].Err != ErrUnsupportedMediaType {
+		t.Error("Expected", ErrUnsupportedMediaType, "got", err)
+	} else if header := errs.ErrorHeader(); header.Code != http.StatusUnsupportedMediaType {
+		t.Error("Expected", http.StatusUnsupportedMediaType, "got", header.Code)
+	}
+	r := New()
+	r.POST("/users/:id", func(c *Control) {
+		if c.Bind(&v) != nil {
+			c.Body(nil)
+		}
+	})
+	req, _ := http.NewRequest("POST", "/users/1", strings.NewReader("a,b"))
+	req.Header.Set("Content-Type", "text/csv")
+	trw := httptest.NewRecorder()
+	r.ServeHTTP(trw, req)
+	if trw.Code != http.StatusUnsupportedMediaType {
+		t.Error("Expected", http.StatusUnsupportedMediaType, "got", trw.Code)
+	}
+	err = testBind("POST", "/users/1", MIMEJSON, "{}", v)
+	if _, ok := err.(BindErrors); err == nil || ok {
+		t.Error("Expected error for non-pointer value, got", err)
+	}
+}
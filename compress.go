@@ -0,0 +1,387 @@
+// Copyright 2015 Igor Dolzhikov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Content codings of responses
+const (
+	encodingGzip     = "gzip"
+	encodingDeflate  = "deflate"
+	encodingIdentity = "identity"
+)
+
+// Compression defines when and how responses are compressed
+type Compression struct {
+	// Level of gzip and deflate compression from gzip.HuffmanOnly to gzip.BestCompression,
+	// zero means gzip.DefaultCompression
+	Level int
+
+	// MinSize is a minimal size of compressed responses in bytes,
+	// smaller responses are not worth compressing
+	MinSize int
+
+	// ContentTypes which are compressed, e.g. "application/json", "text/*" or "application/*+json"
+	ContentTypes []string
+}
+
+// DefaultCompression is used if Router.Compression is not set
+var DefaultCompression = &Compression{
+	MinSize: 1024,
+	ContentTypes: []string{
+		"text/*", "application/json", "application/*+json", "application/xml", "application/*+xml",
+		"application/yaml", "application/javascript", "application/x-ndjson",
+		"application/x-www-form-urlencoded", "image/svg+xml",
+	},
+}
+
+// compressor is a pooled gzip or deflate writer
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// compressorKey identifies a pool of compressors
+type compressorKey struct {
+	encoding string
+	level    int
+}
+
+// compressors contains pools of compressors by encoding and level
+var compressors sync.Map
+
+// level returns the compression level
+func (cmp *Compression) level() int {
+	if cmp.Level == 0 {
+		return gzip.DefaultCompression
+	}
+	return cmp.Level
+}
+
+// compressible checks whether the content type is in the list of compressed types
+func (cmp *Compression) compressible(contentType string) bool {
+	if idx := strings.IndexByte(contentType, ';'); idx >= 0 {
+		contentType = contentType[:idx]
+	}
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	slash := strings.IndexByte(contentType, '/')
+	// event streams must be delivered without buffering
+	if slash < 0 || contentType == MIMEEventStream {
+		return false
+	}
+	for _, pattern := range cmp.ContentTypes {
+		switch {
+		case pattern == contentType:
+			return true
+		case strings.HasSuffix(pattern, "/*") && pattern[:len(pattern)-1] == contentType[:slash+1]:
+			return true
+		case strings.Contains(pattern, "/*+"):
+			// structured syntax suffix, e.g. "application/*+json"
+			prefix, suffix, _ := strings.Cut(pattern, "*")
+			if strings.HasPrefix(contentType, prefix) && strings.HasSuffix(contentType, suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writer returns a pooled compressor of the encoding which writes into w
+func (cmp *Compression) writer(encoding string, w io.Writer) compressor {
+	key := compressorKey{encoding: encoding, level: cmp.level()}
+	pool, ok := compressors.Load(key)
+	if !ok {
+		pool, _ = compressors.LoadOrStore(key, &sync.Pool{
+			New: func() interface{} {
+				var cw compressor
+				if key.encoding == encodingGzip {
+					cw, _ = gzip.NewWriterLevel(nil, key.level)
+				} else {
+					cw, _ = flate.NewWriter(nil, key.level)
+				}
+				return cw
+			},
+		})
+	}
+	cw := pool.(*sync.Pool).Get().(compressor)
+	cw.Reset(w)
+	return cw
+}
+
+// release returns the closed compressor into the pool
+func (cmp *Compression) release(encoding string, cw compressor) {
+	cw.Reset(nil)
+	if pool, ok := compressors.Load(compressorKey{encoding: encoding, level: cmp.level()}); ok {
+		pool.(*sync.Pool).Put(cw)
+	}
+}
+
+// negotiateEncoding returns "gzip" or "deflate" if the encoding is acceptable by
+// the "Accept-Encoding" header and preferred over identity, otherwise it returns empty string
+func negotiateEncoding(acceptEncoding string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+	qualities := make(map[string]float64)
+	for _, item := range strings.Split(acceptEncoding, ",") {
+		parts := strings.Split(item, ";")
+		coding := strings.ToLower(strings.TrimSpace(parts[0]))
+		if coding == "" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range parts[1:] {
+			if key, value, ok := strings.Cut(strings.TrimSpace(param), "="); ok && strings.TrimSpace(key) == "q" {
+				if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q >= 0 && q <= 1 {
+					quality = q
+				}
+			}
+		}
+		qualities[coding] = quality
+	}
+	quality := func(coding string) float64 {
+		if q, ok := qualities[coding]; ok {
+			return q
+		}
+		if q, ok := qualities["*"]; ok {
+			return q
+		}
+		if coding == encodingIdentity {
+			return 1
+		}
+		return 0
+	}
+	best, bestQuality := "", 0.0
+	for _, coding := range []string{encodingGzip, encodingDeflate} {
+		if q := quality(coding); q > bestQuality {
+			best, bestQuality = coding, q
+		}
+	}
+	if best != "" && bestQuality >= quality(encodingIdentity) {
+		return best
+	}
+	return ""
+}
+
+// compression returns compression options of the router
+func (c *Control) compression() *Compression {
+	if c.router != nil && c.router.Compression != nil {
+		return c.router.Compression
+	}
+	return DefaultCompression
+}
+
+// contentEncoding returns the encoding of the response with the content type from
+// headers and the size, negative size means unknown. Empty encoding means no compression.
+func (c *Control) contentEncoding(size int) string {
+	header := c.Writer.Header()
+	cmp := c.compression()
+	if header.Get("Content-Encoding") != "" || !cmp.compressible(header.Get("Content-Type")) {
+		return ""
+	}
+	if size >= 0 && size < cmp.MinSize {
+		return ""
+	}
+	addVary(header, "Accept-Encoding")
+	if c.Request == nil {
+		return ""
+	}
+	return negotiateEncoding(c.Request.Header.Get("Accept-Encoding"))
+}
+
+// Compress is a middleware which compresses responses written directly into
+// Control.Writer, e.g. by routes of Router.Handler and HandlerFunc. It is used for
+// all routes as Router.CustomHandler or for a route by Route.Use. Responses which
+// are compressed by Control.Body or already have "Content-Encoding" are written as is.
+func Compress(next Handle) Handle {
+	return func(c *Control) {
+		if _, ok := c.Writer.(*compressWriter); ok {
+			next(c)
+			return
+		}
+		w := &compressWriter{ResponseWriter: c.Writer, c: c, cmp: c.compression()}
+		c.Writer = w
+		defer func() {
+			if c.events != nil {
+				c.events.Close()
+			}
+			w.Close()
+			c.Writer = w.ResponseWriter
+		}()
+		next(c)
+	}
+}
+
+// compressWriter buffers the beginning of the response until the size
+// is enough to choose the encoding
+type compressWriter struct {
+	http.ResponseWriter
+	c   *Control
+	cmp *Compression
+
+	// compressor and its encoding if the response is compressed
+	cw       compressor
+	encoding string
+
+	code     int
+	buf      []byte
+	started  bool
+	hijacked bool
+}
+
+// WriteHeader delays the status code until the encoding is chosen
+func (w *compressWriter) WriteHeader(code int) {
+	switch {
+	case w.hijacked:
+	case w.started || code < http.StatusOK:
+		w.ResponseWriter.WriteHeader(code)
+	case w.code == 0:
+		w.code = code
+	}
+}
+
+// Write buffers data until MinSize and then writes it through the compressor
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if w.hijacked {
+		return 0, http.ErrHijacked
+	}
+	if w.started {
+		return w.write(data)
+	}
+	w.buf = append(w.buf, data...)
+	if len(w.buf) < w.cmp.MinSize {
+		return len(data), nil
+	}
+	w.start(-1)
+	if _, err := w.flushBuffer(); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// Flush writes buffered data to the client, the encoding is chosen
+// as for the response of unknown size
+func (w *compressWriter) Flush() {
+	if w.hijacked {
+		return
+	}
+	if !w.started {
+		w.start(-1)
+	}
+	w.flushBuffer()
+	if w.cw != nil {
+		w.cw.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets the caller take over the connection, e.g. for WebSocket
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap returns the original writer for http.ResponseController
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Close writes the rest of the response and releases the compressor
+func (w *compressWriter) Close() error {
+	if w.hijacked {
+		return nil
+	}
+	if !w.started {
+		if w.code == 0 && len(w.buf) == 0 {
+			return nil
+		}
+		w.start(len(w.buf))
+	}
+	_, err := w.flushBuffer()
+	if w.cw != nil {
+		if closeErr := w.cw.Close(); err == nil {
+			err = closeErr
+		}
+		w.cmp.release(w.encoding, w.cw)
+		w.cw = nil
+	}
+	return err
+}
+
+// start chooses the encoding by the size of the response,
+// negative size means unknown, and writes the header
+func (w *compressWriter) start(size int) {
+	w.started = true
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	header := w.ResponseWriter.Header()
+	if header.Get("Content-Type") == "" && len(w.buf) > 0 {
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	if w.hasBody() {
+		if length, err := strconv.Atoi(header.Get("Content-Length")); err == nil {
+			size = length
+		}
+		w.encoding = w.c.contentEncoding(size)
+	}
+	if w.encoding != "" {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		if etag := header.Get("ETag"); etag != "" {
+			header.Set("ETag", weakETag(etag))
+		}
+		w.cw = w.cmp.writer(w.encoding, w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.code)
+}
+
+// hasBody checks whether the response has a whole body which may be compressed
+func (w *compressWriter) hasBody() bool {
+	switch {
+	case w.code == http.StatusNoContent, w.code == http.StatusNotModified,
+		w.code == http.StatusPartialContent:
+		return false
+	case w.c.Request != nil && w.c.Request.Method == http.MethodHead:
+		return false
+	}
+	return w.ResponseWriter.Header().Get("Content-Range") == ""
+}
+
+func (w *compressWriter) flushBuffer() (int, error) {
+	if len(w.buf) == 0 {
+		return 0, nil
+	}
+	data := w.buf
+	w.buf = nil
+	return w.write(data)
+}
+
+func (w *compressWriter) write(data []byte) (int, error) {
+	if w.cw != nil {
+		return w.cw.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
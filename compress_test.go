@@ -0,0 +1,214 @@
+package router
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":                           "",
+		"gzip":                       encodingGzip,
+		"gzip, deflate, br":          encodingGzip,
+		"deflate":                    encodingDeflate,
+		"gzip;q=0.5, deflate":        encodingDeflate,
+		"gzip;q=0":                   "",
+		"identity":                   "",
+		"*":                          encodingGzip,
+		"*;q=0, deflate;q=0.1":       encodingDeflate,
+		"gzip;q=0.5, identity":       "",
+		"GZIP;q=1.0, identity;q=0.5": encodingGzip,
+		"br":                         "",
+	}
+	for accept, expected := range tests {
+		if encoding := negotiateEncoding(accept); encoding != expected {
+			t.Error("Expected", expected, "for", accept, "got", encoding)
+		}
+	}
+}
+
+func TestCompressible(t *testing.T) {
+	for _, contentType := range []string{MIMEJSON, "text/plain; charset=utf-8", "application/vnd.api+json", MIMEXML} {
+		if !DefaultCompression.compressible(contentType) {
+			t.Error("Expected", contentType, "to be compressible")
+		}
+	}
+	for _, contentType := range []string{"image/png", "application/octet-stream", ""} {
+		if DefaultCompression.compressible(contentType) {
+			t.Error("Expected", contentType, "not to be compressible")
+		}
+	}
+}
+
+func TestControlBodyCompression(t *testing.T) {
+	r := New()
+	large := strings.Repeat("compressible text ", 100)
+	r.GET("/small", func(c *Control) {
+		c.Body("tiny")
+	})
+	r.GET("/large", func(c *Control) {
+		c.Body(large)
+	})
+	r.GET("/image", func(c *Control) {
+		c.ContentType = "image/png"
+		c.Body(large)
+	})
+
+	trw := testRequest(r, "GET", "/small", map[string]string{"Accept-Encoding": "gzip"})
+	if trw.Header().Get("Content-Encoding") != "" || trw.Body.String() != "tiny" {
+		t.Error("Expected not compressed small response, got", trw.Header().Get("Content-Encoding"))
+	}
+	if trw = testRequest(r, "GET", "/image", map[string]string{"Accept-Encoding": "gzip"}); trw.Header().Get("Content-Encoding") != "" {
+		t.Error("Expected not compressed image, got", trw.Header().Get("Content-Encoding"))
+	}
+
+	trw = testRequest(r, "GET", "/large", map[string]string{"Accept-Encoding": "gzip"})
+	if trw.Header().Get("Content-Encoding") != "gzip" || trw.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatal("Expected gzip response with Vary, got", trw.Header())
+	}
+	gz, err := gzip.NewReader(trw.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := ioutil.ReadAll(gz); string(content) != large {
+		t.Error("Expected", len(large), "bytes, got", len(content))
+	}
+
+	trw = testRequest(r, "GET", "/large", map[string]string{"Accept-Encoding": "gzip;q=0.1, deflate"})
+	if trw.Header().Get("Content-Encoding") != "deflate" {
+		t.Fatal("Expected deflate response, got", trw.Header().Get("Content-Encoding"))
+	}
+	if content, _ := ioutil.ReadAll(flate.NewReader(trw.Body)); string(content) != large {
+		t.Error("Expected", len(large), "bytes, got", len(content))
+	}
+
+	trw = testRequest(r, "GET", "/large", map[string]string{"Accept-Encoding": "gzip;q=0"})
+	if trw.Header().Get("Content-Encoding") != "" || trw.Body.String() != large {
+		t.Error("Expected identity response, got", trw.Header().Get("Content-Encoding"))
+	}
+
+	// router options
+	r.Compression = &Compression{Level: gzip.BestSpeed, ContentTypes: []string{"image/*"}}
+	if trw = testRequest(r, "GET", "/small", map[string]string{"Accept-Encoding": "gzip"}); trw.Header().Get("Content-Encoding") != "" {
+		t.Error("Expected not compressed text, got", trw.Header().Get("Content-Encoding"))
+	}
+	trw = testRequest(r, "GET", "/image", map[string]string{"Accept-Encoding": "gzip"})
+	if trw.Header().Get("Content-Encoding") != "gzip" || trw.Code != http.StatusOK {
+		t.Error("Expected compressed image, got", trw.Header().Get("Content-Encoding"))
+	}
+}
+
+func TestCompressMiddleware(t *testing.T) {
+	r := New()
+	large := strings.Repeat("compressible text ", 100)
+	r.HandlerFunc("GET", "/handler", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", MIMETEXT)
+		w.WriteHeader(http.StatusAccepted)
+		for idx := 0; idx < 10; idx++ {
+			w.Write([]byte(large[:len(large)/10]))
+		}
+	}).Use(Compress)
+	r.HandlerFunc("GET", "/small", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("<html>tiny</html>"))
+	}).Use(Compress)
+	r.GET("/body", func(c *Control) {
+		c.Body(large)
+	}).Use(Compress)
+	r.HandlerFunc("GET", "/encoded", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Encoding", "br")
+		w.Write([]byte(large))
+	}).Use(Compress)
+	r.GET("/empty", func(c *Control) {
+		c.Code(http.StatusNoContent).Writer.WriteHeader(http.StatusNoContent)
+	}).Use(Compress)
+
+	header := map[string]string{"Accept-Encoding": "gzip"}
+	trw := testRequest(r, "GET", "/handler", header)
+	if trw.Code != http.StatusAccepted || trw.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatal("Expected", http.StatusAccepted, "gzip", "got", trw.Code, trw.Header().Get("Content-Encoding"))
+	}
+	gz, err := gzip.NewReader(trw.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := ioutil.ReadAll(gz); string(content) != large {
+		t.Error("Expected", len(large), "bytes, got", len(content))
+	}
+
+	trw = testRequest(r, "GET", "/small", header)
+	if trw.Header().Get("Content-Encoding") != "" || trw.Body.String() != "<html>tiny</html>" {
+		t.Error("Expected not compressed small response, got", trw.Header().Get("Content-Encoding"))
+	}
+	if contentType := trw.Header().Get("Content-Type"); contentType != "text/html; charset=utf-8" {
+		t.Error("Expected sniffed content type, got", contentType)
+	}
+
+	// compressed once
+	trw = testRequest(r, "GET", "/body", header)
+	if trw.Header()["Vary"][0] != "Accept-Encoding" || len(trw.Header()["Vary"]) != 1 {
+		t.Error("Expected single Vary, got", trw.Header()["Vary"])
+	}
+	if gz, err = gzip.NewReader(trw.Body); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := ioutil.ReadAll(gz); string(content) != large {
+		t.Error("Expected", len(large), "bytes, got", len(content))
+	}
+
+	trw = testRequest(r, "GET", "/encoded", header)
+	if trw.Header().Get("Content-Encoding") != "br" || trw.Body.String() != large {
+		t.Error("Expected response as is, got", trw.Header().Get("Content-Encoding"))
+	}
+	if trw = testRequest(r, "GET", "/empty", header); trw.Code != http.StatusNoContent || trw.Header().Get("Content-Encoding") != "" {
+		t.Error("Expected", http.StatusNoContent, "got", trw.Code, trw.Header().Get("Content-Encoding"))
+	}
+}
+
+func TestCompressMiddlewareFlush(t *testing.T) {
+	r := New()
+	r.CustomHandler = Compress
+	r.HandlerFunc("GET", "/flush", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", MIMEJSON)
+		w.Write([]byte(`{"a":`))
+		w.(http.Flusher).Flush()
+		w.Write([]byte(`1}`))
+	})
+	r.GET("/events", func(c *Control) {
+		c.SSE().Send("", "", "message")
+	})
+
+	trw := testRequest(r, "GET", "/flush", map[string]string{"Accept-Encoding": "deflate"})
+	if trw.Header().Get("Content-Encoding") != "deflate" || !trw.Flushed {
+		t.Fatal("Expected flushed deflate response, got", trw.Header().Get("Content-Encoding"))
+	}
+	if content, _ := ioutil.ReadAll(flate.NewReader(trw.Body)); string(content) != `{"a":1}` {
+		t.Error("Expected", `{"a":1}`, "got", string(content))
+	}
+
+	trw = testRequest(r, "GET", "/events", map[string]string{"Accept-Encoding": "gzip"})
+	if trw.Header().Get("Content-Encoding") != "" || trw.Body.String() != "data: message\n\n" {
+		t.Error("Expected not compressed event stream, got", trw.Header().Get("Content-Encoding"), trw.Body.String())
+	}
+}
+
+func TestCompressMiddlewareWebSocket(t *testing.T) {
+	errs := make(chan error, 1)
+	server := testWebSocketServer(nil, errs)
+	defer server.Close()
+	server.Config.Handler.(*Router).CustomHandler = Compress
+
+	conn, _, err := DialWebSocket(server.URL+"/ws", http.Header{"Accept-Encoding": {"gzip"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.WriteMessage(TextMessage, []byte("hijacked"))
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "hijacked" {
+		t.Error("Expected", "hijacked", "got", string(data), err)
+	}
+	conn.Close()
+	<-errs
+}
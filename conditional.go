@@ -0,0 +1,238 @@
+// Copyright 2015 Igor Dolzhikov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ETagMode defines whether Control.Body generates ETags from the content
+type ETagMode uint8
+
+// Modes of generated ETags
+const (
+	// NoETag disables generated ETags, explicit ETags are still used
+	NoETag ETagMode = iota
+	// StrongETag is a hash of the rendered content
+	StrongETag
+	// WeakETag is a hash of the rendered content with "W/" prefix
+	WeakETag
+)
+
+// ETag sets the entity tag of the response instead of generated one,
+// quotes are added if they are missing, e.g. "123" or W/"123"
+func (c *Control) ETag(etag string) *Control {
+	c.checkReleased()
+	if etag != "" && !strings.HasSuffix(etag, `"`) {
+		etag = `"` + etag + `"`
+	}
+	c.etag = etag
+	return c
+}
+
+// LastModified sets the modification time of the response
+func (c *Control) LastModified(modified time.Time) *Control {
+	c.checkReleased()
+	c.lastModified = modified
+	return c
+}
+
+// StateFunc returns the current entity tag and modification time of the resource
+// of the request, both are empty if the resource does not exist
+type StateFunc func(c *Control) (etag string, modified time.Time, err error)
+
+// Precondition enforces "If-Match" and "If-Unmodified-Since" headers of requests
+// with the current state of the resource before the handler is called,
+// e.g. for PUT, PATCH and DELETE. Failed preconditions are answered
+// by "412 Precondition Failed" with ErrorHeader.
+func (rt *Route) Precondition(state StateFunc) *Route {
+	rt.lock()
+	defer rt.unlock()
+	rt.state = state
+	rt.stateRequired = false
+	rt.compose()
+	return rt
+}
+
+// RequirePrecondition is the same as Precondition, but requests without
+// the headers are answered by "428 Precondition Required"
+func (rt *Route) RequirePrecondition(state StateFunc) *Route {
+	rt.lock()
+	defer rt.unlock()
+	rt.state = state
+	rt.stateRequired = true
+	rt.compose()
+	return rt
+}
+
+// ETag sets the mode of generated ETags of the route instead of Router.ETag
+func (rt *Route) ETag(mode ETagMode) *Route {
+	rt.etag = mode
+	rt.hasETag = true
+	return rt
+}
+
+// etagMode returns the mode of generated ETags of the route or the router
+func (c *Control) etagMode() ETagMode {
+	if c.route != nil && c.route.hasETag {
+		return c.route.etag
+	}
+	if c.router != nil {
+		return c.router.ETag
+	}
+	return NoETag
+}
+
+// cacheable checks whether validators are used for the response
+func (c *Control) cacheable() bool {
+	if c.code != 0 && c.code != http.StatusOK {
+		return false
+	}
+	return c.Request == nil || c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead
+}
+
+// validate sets ETag and Last-Modified headers of the content and returns true
+// if the client has the same representation, then 304 is written
+func (c *Control) validate(content []byte) bool {
+	if !c.cacheable() {
+		return false
+	}
+	header := c.Writer.Header()
+	etag := c.etag
+	if etag == "" {
+		switch c.etagMode() {
+		case StrongETag:
+			etag = contentETag(content)
+		case WeakETag:
+			etag = "W/" + contentETag(content)
+		}
+	}
+	if etag != "" {
+		header.Set("ETag", etag)
+	}
+	if !c.lastModified.IsZero() {
+		header.Set("Last-Modified", c.lastModified.UTC().Format(http.TimeFormat))
+	}
+	if c.Request == nil || !c.notModified(etag) {
+		return false
+	}
+	header.Del("Content-type")
+	header.Del("Content-Length")
+	c.Writer.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// notModified checks "If-None-Match" and "If-Modified-Since" headers of the request
+func (c *Control) notModified(etag string) bool {
+	if match := c.Request.Header.Get("If-None-Match"); match != "" {
+		return etag != "" && etagListMatch(match, etag, true)
+	}
+	since, err := http.ParseTime(c.Request.Header.Get("If-Modified-Since"))
+	if err != nil || c.lastModified.IsZero() {
+		return false
+	}
+	return !c.lastModified.Truncate(time.Second).After(since)
+}
+
+// contentETag returns a strong entity tag of the content
+func contentETag(content []byte) string {
+	sum := sha256.Sum256(content)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// weakETag returns the weak form of the entity tag
+func weakETag(etag string) string {
+	if etag == "" || strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return "W/" + etag
+}
+
+// etagListMatch checks whether the list of entity tags from "If-None-Match" or "If-Match"
+// contains the tag, weak comparison ignores "W/" prefixes, strong comparison
+// never matches weak tags
+func etagListMatch(list, etag string, weak bool) bool {
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+	if !weak && strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for list != "" {
+		list = strings.TrimLeft(list, " \t,")
+		isWeak := strings.HasPrefix(list, "W/")
+		list = strings.TrimPrefix(list, "W/")
+		if !strings.HasPrefix(list, `"`) {
+			return false
+		}
+		end := strings.IndexByte(list[1:], '"')
+		if end < 0 {
+			return false
+		}
+		tag := list[:end+2]
+		list = list[end+2:]
+		if tag == etag && (weak || !isWeak) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPreconditions wraps the handler by the check of preconditions with the state
+func checkPreconditions(handle Handle, state StateFunc, required bool) Handle {
+	return func(c *Control) {
+		match := c.Request.Header.Get("If-Match")
+		// the empty header is not parsed to keep requests without preconditions free of allocations
+		var since time.Time
+		hasSince := false
+		if value := c.Request.Header.Get("If-Unmodified-Since"); value != "" {
+			var err error
+			since, err = http.ParseTime(value)
+			hasSince = err == nil
+		}
+		if match == "" && !hasSince {
+			if required {
+				c.renderError(http.StatusPreconditionRequired, Error{
+					Reason:  "required",
+					Message: "If-Match or If-Unmodified-Since header is required",
+				})
+				return
+			}
+			handle(c)
+			return
+		}
+		etag, modified, err := state(c)
+		if err != nil {
+			c.fail(OpPrecondition, err)
+			return
+		}
+		failed := ""
+		switch {
+		case match == "*":
+			if etag == "" && modified.IsZero() {
+				failed = "If-Match"
+			}
+		case match != "":
+			if etag == "" || !etagListMatch(match, etag, false) {
+				failed = "If-Match"
+			}
+		case !modified.IsZero() && modified.Truncate(time.Second).After(since):
+			failed = "If-Unmodified-Since"
+		}
+		if failed != "" {
+			c.renderError(http.StatusPreconditionFailed, Error{
+				Reason:  "conditionNotMet",
+				Message: failed + " precondition failed",
+			})
+			return
+		}
+		handle(c)
+	}
+}
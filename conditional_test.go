@@ -0,0 +1,187 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestETagListMatch(t *testing.T) {
+	tests := []struct {
+		list, etag string
+		weak       bool
+		expected   bool
+	}{
+		{`"a"`, `"a"`, false, true},
+		{`"b", "a"`, `"a"`, false, true},
+		{`"b","c"`, `"a"`, true, false},
+		{`W/"a"`, `"a"`, true, true},
+		{`W/"a"`, `"a"`, false, false},
+		{`"a"`, `W/"a"`, true, true},
+		{`"a"`, `W/"a"`, false, false},
+		{`*`, `"a"`, false, true},
+		{`"a,b", "c"`, `"a,b"`, false, true},
+		{`a`, `"a"`, true, false},
+	}
+	for _, test := range tests {
+		if match := etagListMatch(test.list, test.etag, test.weak); match != test.expected {
+			t.Error("Expected", test.expected, "for", test.list, test.etag, test.weak, "got", match)
+		}
+	}
+}
+
+func TestControlBodyETag(t *testing.T) {
+	r := New()
+	r.ETag = StrongETag
+	r.GET("/strong", func(c *Control) {
+		c.Body(map[string]string{"status": "ok"})
+	})
+	r.GET("/weak", func(c *Control) {
+		c.Body(map[string]string{"status": "ok"})
+	}).ETag(WeakETag)
+	r.GET("/none", func(c *Control) {
+		c.Body(map[string]string{"status": "ok"})
+	}).ETag(NoETag)
+	r.GET("/explicit", func(c *Control) {
+		c.ETag("v1").Body(map[string]string{"status": "ok"})
+	}).ETag(NoETag)
+	r.GET("/created", func(c *Control) {
+		c.Code(http.StatusCreated).Body(map[string]string{"status": "ok"})
+	})
+
+	trw := testRequest(r, "GET", "/strong", nil)
+	etag := trw.Header().Get("ETag")
+	if trw.Code != http.StatusOK || len(etag) != 34 || !strings.HasPrefix(etag, `"`) {
+		t.Fatal("Expected strong ETag, got", trw.Code, etag)
+	}
+	trw = testRequest(r, "GET", "/strong", map[string]string{"If-None-Match": `"other", ` + etag})
+	if trw.Code != http.StatusNotModified || trw.Body.Len() != 0 || trw.Header().Get("ETag") != etag {
+		t.Error("Expected", http.StatusNotModified, "got", trw.Code, trw.Body.String())
+	}
+	if trw.Header().Get("Content-type") != "" {
+		t.Error("Expected no content type, got", trw.Header().Get("Content-type"))
+	}
+	if trw = testRequest(r, "GET", "/strong", map[string]string{"If-None-Match": `"other"`}); trw.Code != http.StatusOK {
+		t.Error("Expected", http.StatusOK, "got", trw.Code)
+	}
+
+	trw = testRequest(r, "GET", "/weak", map[string]string{"If-None-Match": etag})
+	if trw.Code != http.StatusNotModified || trw.Header().Get("ETag") != "W/"+etag {
+		t.Error("Expected", http.StatusNotModified, "W/"+etag, "got", trw.Code, trw.Header().Get("ETag"))
+	}
+	if trw = testRequest(r, "GET", "/none", nil); trw.Header().Get("ETag") != "" {
+		t.Error("Expected no ETag, got", trw.Header().Get("ETag"))
+	}
+	trw = testRequest(r, "GET", "/explicit", map[string]string{"If-None-Match": `"v1"`})
+	if trw.Code != http.StatusNotModified || trw.Header().Get("ETag") != `"v1"` {
+		t.Error("Expected", http.StatusNotModified, `"v1"`, "got", trw.Code, trw.Header().Get("ETag"))
+	}
+	if trw = testRequest(r, "GET", "/created", map[string]string{"If-None-Match": "*"}); trw.Code != http.StatusCreated || trw.Header().Get("ETag") != "" {
+		t.Error("Expected", http.StatusCreated, "without ETag, got", trw.Code, trw.Header().Get("ETag"))
+	}
+}
+
+func TestControlBodyLastModified(t *testing.T) {
+	modified := time.Date(2020, 1, 2, 3, 4, 5, 600, time.UTC)
+	r := New()
+	r.GET("/modified", func(c *Control) {
+		c.LastModified(modified).Body("content")
+	})
+
+	trw := testRequest(r, "GET", "/modified", nil)
+	if trw.Code != http.StatusOK || trw.Header().Get("Last-Modified") != "Thu, 02 Jan 2020 03:04:05 GMT" {
+		t.Error("Expected Last-Modified, got", trw.Code, trw.Header().Get("Last-Modified"))
+	}
+	trw = testRequest(r, "GET", "/modified", map[string]string{"If-Modified-Since": "Thu, 02 Jan 2020 03:04:05 GMT"})
+	if trw.Code != http.StatusNotModified || trw.Body.Len() != 0 {
+		t.Error("Expected", http.StatusNotModified, "got", trw.Code)
+	}
+	trw = testRequest(r, "GET", "/modified", map[string]string{"If-Modified-Since": "Thu, 02 Jan 2020 03:04:04 GMT"})
+	if trw.Code != http.StatusOK || trw.Body.String() != "content" {
+		t.Error("Expected", http.StatusOK, "got", trw.Code)
+	}
+	// If-None-Match takes precedence
+	trw = testRequest(r, "GET", "/modified", map[string]string{
+		"If-Modified-Since": "Thu, 02 Jan 2020 03:04:05 GMT",
+		"If-None-Match":     `"v1"`,
+	})
+	if trw.Code != http.StatusOK {
+		t.Error("Expected", http.StatusOK, "got", trw.Code)
+	}
+}
+
+func TestControlBodyETagCompression(t *testing.T) {
+	r := New()
+	r.ETag = StrongETag
+	r.GET("/large", func(c *Control) {
+		c.Body(strings.Repeat("compressible text ", 100))
+	})
+	trw := testRequest(r, "GET", "/large", map[string]string{"Accept-Encoding": "gzip"})
+	etag := trw.Header().Get("ETag")
+	if trw.Header().Get("Content-Encoding") != "gzip" || !strings.HasPrefix(etag, `W/"`) {
+		t.Fatal("Expected weak ETag of compressed response, got", etag)
+	}
+	trw = testRequest(r, "GET", "/large", map[string]string{"Accept-Encoding": "gzip", "If-None-Match": etag})
+	if trw.Code != http.StatusNotModified {
+		t.Error("Expected", http.StatusNotModified, "got", trw.Code)
+	}
+}
+
+func TestRoutePrecondition(t *testing.T) {
+	modified := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	documents := map[string]string{"1": `"v2"`}
+	state := func(c *Control) (string, time.Time, error) {
+		if c.Get(":id") == "fail" {
+			return "", time.Time{}, errors.New("storage is unavailable")
+		}
+		if etag, ok := documents[c.Get(":id")]; ok {
+			return etag, modified, nil
+		}
+		return "", time.Time{}, nil
+	}
+	r := New()
+	r.PUT("/docs/:id", func(c *Control) {
+		c.Code(http.StatusNoContent).Body("")
+	}).RequirePrecondition(state)
+	r.DELETE("/docs/:id", func(c *Control) {
+		c.Code(http.StatusNoContent).Body("")
+	}).Precondition(state)
+
+	tests := []struct {
+		method, path string
+		header       map[string]string
+		expected     int
+	}{
+		{"PUT", "/docs/1", nil, http.StatusPreconditionRequired},
+		{"PUT", "/docs/1", map[string]string{"If-Match": `"v2"`}, http.StatusNoContent},
+		{"PUT", "/docs/1", map[string]string{"If-Match": `"v1", "v2"`}, http.StatusNoContent},
+		{"PUT", "/docs/1", map[string]string{"If-Match": `"v1"`}, http.StatusPreconditionFailed},
+		{"PUT", "/docs/1", map[string]string{"If-Match": `W/"v2"`}, http.StatusPreconditionFailed},
+		{"PUT", "/docs/1", map[string]string{"If-Match": "*"}, http.StatusNoContent},
+		{"PUT", "/docs/2", map[string]string{"If-Match": "*"}, http.StatusPreconditionFailed},
+		{"PUT", "/docs/1", map[string]string{"If-Unmodified-Since": "Thu, 02 Jan 2020 03:04:05 GMT"}, http.StatusNoContent},
+		{"PUT", "/docs/1", map[string]string{"If-Unmodified-Since": "Thu, 02 Jan 2020 03:04:04 GMT"}, http.StatusPreconditionFailed},
+		// If-Match takes precedence
+		{"PUT", "/docs/1", map[string]string{"If-Match": `"v2"`, "If-Unmodified-Since": "Thu, 02 Jan 2020 03:04:04 GMT"}, http.StatusNoContent},
+		{"PUT", "/docs/fail", map[string]string{"If-Match": `"v2"`}, http.StatusInternalServerError},
+		{"DELETE", "/docs/1", nil, http.StatusNoContent},
+		{"DELETE", "/docs/1", map[string]string{"If-Match": `"v1"`}, http.StatusPreconditionFailed},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest(test.method, test.path, nil)
+		for key, value := range test.header {
+			req.Header.Set(key, value)
+		}
+		trw := httptest.NewRecorder()
+		r.ServeHTTP(trw, req)
+		if trw.Code != test.expected {
+			t.Error("Expected", test.expected, "for", test.method, test.path, test.header, "got", trw.Code)
+		}
+		if trw.Code == http.StatusPreconditionFailed && !strings.Contains(trw.Body.String(), `"reason": "conditionNotMet"`) {
+			t.Error("Expected error envelope, got", trw.Body.String())
+		}
+	}
+}
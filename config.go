@@ -0,0 +1,345 @@
+// Copyright 2015 Igor Dolzhikov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// Formats of route files
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// Registry contains named handlers and middleware which may be used in route files
+type Registry struct {
+	handlers   map[string]Handle
+	middleware map[string]Middleware
+}
+
+// RouteConfig describes a route in a route file
+type RouteConfig struct {
+	Method      string            `json:"method"`
+	Path        string            `json:"path"`
+	Handler     string            `json:"handler"`
+	Name        string            `json:"name,omitempty"`
+	Middleware  []string          `json:"middleware,omitempty"`
+	Disabled    bool              `json:"disabled,omitempty"`
+	Summary     string            `json:"summary,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// RouteFile is a content of a route file
+type RouteFile struct {
+	Routes []RouteConfig `json:"routes"`
+}
+
+// ConfigError reports a problem in a route file
+type ConfigError struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Message)
+}
+
+// ConfigErrors contains all problems found in a route file
+type ConfigErrors []*ConfigError
+
+func (e ConfigErrors) Error() string {
+	messages := make([]string, len(e))
+	for idx, err := range e {
+		messages[idx] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// NewRegistry returns a new empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		handlers:   make(map[string]Handle),
+		middleware: make(map[string]Middleware),
+	}
+}
+
+// Handler registers named handler
+func (reg *Registry) Handler(name string, h Handle) *Registry {
+	reg.handlers[name] = h
+	return reg
+}
+
+// Middleware registers named middleware
+func (reg *Registry) Middleware(name string, m Middleware) *Registry {
+	reg.middleware[name] = m
+	return reg
+}
+
+// handlerName looks for a name of the registered handler
+func (reg *Registry) handlerName(h Handle) string {
+	if reg == nil || h == nil {
+		return ""
+	}
+	pointer := reflect.ValueOf(h).Pointer()
+	for name, handle := range reg.handlers {
+		if reflect.ValueOf(handle).Pointer() == pointer {
+			return name
+		}
+	}
+	return ""
+}
+
+// LoadRoutes reads the route file in JSON or YAML format (by extension of the file)
+// and registers its routes. Routes loaded earlier from the same file are replaced,
+// so the method may be used to reload routes on a live router.
+func (r *Router) LoadRoutes(filename string, registry *Registry) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	format := FormatJSON
+	if ext := strings.ToLower(filepath.Ext(filename)); ext == ".yaml" || ext == ".yml" {
+		format = FormatYAML
+	}
+	return r.ReadRoutes(filename, bytes.NewReader(data), format, registry)
+}
+
+// ReadRoutes reads routes in the format from the reader and registers them.
+// Source is a name which is used in errors and to replace routes loaded earlier.
+// Nothing is changed if any of routes is not valid.
+func (r *Router) ReadRoutes(source string, reader io.Reader, format string, registry *Registry) error {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	var root *node
+	switch format {
+	case FormatJSON:
+		root, err = decodeNode(data)
+		if err != nil {
+			return &ConfigError{File: source, Line: jsonErrorLine(data, err), Message: err.Error()}
+		}
+	case FormatYAML:
+		root, err = decodeYAML(data)
+		if err != nil {
+			if e, ok := err.(*YAMLError); ok {
+				return &ConfigError{File: source, Line: e.Line, Message: e.Message}
+			}
+			return err
+		}
+	default:
+		return fmt.Errorf("router: unknown format of routes %q", format)
+	}
+	routes, err := buildRoutes(source, root, registry)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, route := range routes {
+		route.router = r
+	}
+	list := make([]*Route, 0, len(r.routes)+len(routes))
+	for _, route := range r.routes {
+		if route.source != source {
+			list = append(list, route)
+		}
+	}
+	r.routes = append(list, routes...)
+	r.rebuild()
+
+	return nil
+}
+
+// rebuild creates new parsers for the list of routes, handlers of routes are not composed again
+func (r *Router) rebuild() {
+	r.handlers = make(map[string]*parser)
+	for _, route := range r.routes {
+		if !route.disabled {
+			r.add(route)
+		}
+	}
+}
+
+// ExportRoutes writes all registered routes in the format,
+// names of handlers and middleware are taken from the registry
+func (r *Router) ExportRoutes(w io.Writer, format string, registry *Registry) error {
+	r.mu.RLock()
+	file := RouteFile{Routes: make([]RouteConfig, 0, len(r.routes))}
+	for _, route := range r.routes {
+		name := route.handlerName
+		if name == "" {
+			name = registry.handlerName(route.handle)
+		}
+		file.Routes = append(file.Routes, RouteConfig{
+			Method:      route.Method,
+			Path:        route.Path,
+			Handler:     name,
+			Name:        route.name,
+			Middleware:  route.middlewareNames,
+			Disabled:    route.disabled,
+			Summary:     route.summary,
+			Description: route.description,
+			Tags:        route.tags,
+			Metadata:    route.metadata,
+		})
+	}
+	r.mu.RUnlock()
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	switch format {
+	case FormatJSON:
+		data = append(data, '\n')
+	case FormatYAML:
+		if data, err = jsonToYAML(data); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("router: unknown format of routes %q", format)
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// buildRoutes validates route descriptions and creates routes
+func buildRoutes(source string, root *node, registry *Registry) ([]*Route, error) {
+	var errs ConfigErrors
+	fail := func(line int, format string, args ...interface{}) {
+		errs = append(errs, &ConfigError{File: source, Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+	list := root
+	if root.isMap {
+		list = nil
+		for idx, key := range root.keys {
+			if key == "routes" {
+				list = root.values[idx]
+			} else {
+				fail(root.values[idx].line, "unknown field %q", key)
+			}
+		}
+	}
+	if list == nil || !list.isArray {
+		fail(root.line, "expected list of routes")
+		return nil, errs
+	}
+	if registry == nil {
+		registry = NewRegistry()
+	}
+	var routes []*Route
+	seen := make(map[string]int)
+	for _, item := range list.items {
+		if !item.isMap {
+			fail(item.line, "expected route description")
+			continue
+		}
+		line := func(field string) int {
+			for idx, key := range item.keys {
+				if key == field {
+					return item.values[idx].line
+				}
+			}
+			return item.line
+		}
+		var config RouteConfig
+		decoder := json.NewDecoder(bytes.NewReader(item.json()))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&config); err != nil {
+			if e, ok := err.(*json.UnmarshalTypeError); ok {
+				fail(line(e.Field), "invalid value of %q: expected %s", e.Field, e.Type)
+			} else {
+				fail(item.line, "%s", strings.TrimPrefix(err.Error(), "json: "))
+			}
+			continue
+		}
+		if config.Method == "" {
+			fail(item.line, "method is required")
+		} else if strings.ToUpper(config.Method) != config.Method || strings.ContainsAny(config.Method, " /") {
+			fail(line("method"), "invalid method %q", config.Method)
+		}
+		if config.Path == "" {
+			fail(item.line, "path is required")
+		} else if !strings.HasPrefix(config.Path, "/") && config.Path != asterisk {
+			fail(line("path"), "path %q must start with '/'", config.Path)
+		} else if _, ok := split(config.Path); !ok {
+			fail(line("path"), "path %q has too many segments", config.Path)
+		}
+		handle, ok := registry.handlers[config.Handler]
+		if config.Handler == "" {
+			fail(item.line, "handler is required")
+		} else if !ok {
+			fail(line("handler"), "unknown handler %q", config.Handler)
+		}
+		route := &Route{
+			Method:          config.Method,
+			Path:            config.Path,
+			handle:          handle,
+			source:          source,
+			handlerName:     config.Handler,
+			name:            config.Name,
+			middlewareNames: config.Middleware,
+			disabled:        config.Disabled,
+			summary:         config.Summary,
+			description:     config.Description,
+			tags:            config.Tags,
+			metadata:        config.Metadata,
+		}
+		for _, name := range config.Middleware {
+			if middleware, ok := registry.middleware[name]; ok {
+				route.middleware = append(route.middleware, middleware)
+			} else {
+				fail(line("middleware"), "unknown middleware %q", name)
+			}
+		}
+		key := config.Method + " " + config.Path
+		if parts, ok := split(config.Path); ok && config.Path != asterisk {
+			key = config.Method + " /" + join(parts)
+		}
+		if previous, ok := seen[key]; ok {
+			fail(item.line, "duplicate route %s, see line %d", key, previous)
+		}
+		seen[key] = item.line
+		routes = append(routes, route)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	for _, route := range routes {
+		route.compose()
+	}
+
+	return routes, nil
+}
+
+// jsonErrorLine returns a number of line where JSON decoding error happened
+func jsonErrorLine(data []byte, err error) int {
+	var offset int64
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		offset = e.Offset
+	case *json.UnmarshalTypeError:
+		offset = e.Offset
+	default:
+		offset = int64(len(data))
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
@@ -0,0 +1,223 @@
+package router
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testRoutesYAML = `# routes of users service
+routes:
+  - method: GET
+    path: /users/:id
+    handler: getUser
+    middleware: [auth]
+    summary: Get user
+    tags:
+      - users
+  - method: GET
+    path: /people/:id   # alias
+    handler: getUser
+  - method: DELETE
+    path: /users/:id
+    handler: getUser
+    disabled: true
+`
+
+func testRegistry() *Registry {
+	return NewRegistry().
+		Handler("getUser", func(c *Control) {
+			c.Body("User " + c.Get(":id") + c.Get("auth"))
+		}).
+		Handler("hello", func(c *Control) {
+			c.Body("Hello")
+		}).
+		Middleware("auth", func(next Handle) Handle {
+			return func(c *Control) {
+				c.Set(Param{Key: "auth", Value: " (authorized)"})
+				next(c)
+			}
+		})
+}
+
+func TestRouterLoadRoutes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "router")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "routes.yaml")
+	if err := ioutil.WriteFile(filename, []byte(testRoutesYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := New()
+	r.GET("/hello", func(c *Control) { c.Body("Hello") })
+	registry := testRegistry()
+	if err := r.LoadRoutes(filename, registry); err != nil {
+		t.Fatal(err)
+	}
+	if body := testRequest(r, "GET", "/users/17", nil).Body.String(); body != "User 17 (authorized)" {
+		t.Error("Expected", "User 17 (authorized)", "got", body)
+	}
+	if body := testRequest(r, "GET", "/people/18", nil).Body.String(); body != "User 18" {
+		t.Error("Expected", "User 18", "got", body)
+	}
+	if code := testRequest(r, "DELETE", "/users/17", nil).Code; code != http.StatusMethodNotAllowed {
+		t.Error("Expected", http.StatusMethodNotAllowed, "got", code)
+	}
+
+	// reload without alias
+	reloaded := strings.Replace(testRoutesYAML, "/people/:id", "/persons/:id", 1)
+	if err := ioutil.WriteFile(filename, []byte(reloaded), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.LoadRoutes(filename, registry); err != nil {
+		t.Fatal(err)
+	}
+	if code := testRequest(r, "GET", "/people/18", nil).Code; code != http.StatusNotFound {
+		t.Error("Expected", http.StatusNotFound, "got", code)
+	}
+	if body := testRequest(r, "GET", "/persons/18", nil).Body.String(); body != "User 18" {
+		t.Error("Expected", "User 18", "got", body)
+	}
+	if body := testRequest(r, "GET", "/hello", nil).Body.String(); body != "Hello" {
+		t.Error("Expected", "Hello", "got", body)
+	}
+	if len(r.routes) != 4 {
+		t.Error("Expected routes", 4, "got", len(r.routes))
+	}
+}
+
+func TestRouterReadRoutesErrors(t *testing.T) {
+	data := `{
+  "routes": [
+    {"method": "GET", "path": "/users/:id", "handler": "getUser"},
+    {
+      "method": "get",
+      "path": "users",
+      "handler": "unknown"
+    },
+    {"method": "GET", "path": "/users/:id/", "handler": "getUser", "middleware": ["cache"]},
+    {"method": "POST", "path": "/users", "handler": 1}
+  ]
+}`
+	r := New()
+	err := r.ReadRoutes("routes.json", strings.NewReader(data), FormatJSON, testRegistry())
+	errs, ok := err.(ConfigErrors)
+	if !ok {
+		t.Fatal("Expected ConfigErrors, got", err)
+	}
+	expected := []string{
+		`routes.json:5: invalid method "get"`,
+		`routes.json:6: path "users" must start with '/'`,
+		`routes.json:7: unknown handler "unknown"`,
+		`routes.json:9: unknown middleware "cache"`,
+		`routes.json:9: duplicate route GET /users/:id, see line 3`,
+		`routes.json:10: invalid value of "handler": expected string`,
+	}
+	if errs.Error() != strings.Join(expected, "\n") {
+		t.Error("Expected", strings.Join(expected, "\n"), "got", errs.Error())
+	}
+	if len(r.routes) != 0 {
+		t.Error("Expected no routes, got", len(r.routes))
+	}
+
+	err = r.ReadRoutes("routes.yaml", strings.NewReader("routes:\n  - method: GET\n   path: /\n"), FormatYAML, nil)
+	if e, ok := err.(*ConfigError); !ok || e.Line != 3 {
+		t.Error("Expected error on line 3, got", err)
+	}
+	err = r.ReadRoutes("routes.json", strings.NewReader("[\n{\"method\": \"GET\",,}]"), FormatJSON, nil)
+	if e, ok := err.(*ConfigError); !ok || e.Line != 2 {
+		t.Error("Expected error on line 2, got", err)
+	}
+}
+
+func TestRouterExportRoutes(t *testing.T) {
+	registry := testRegistry()
+	r := New()
+	if err := r.ReadRoutes("routes.yaml", strings.NewReader(testRoutesYAML), FormatYAML, registry); err != nil {
+		t.Fatal(err)
+	}
+	r.GET("/hello", registry.handlers["hello"]).SetMeta("owner", "team")
+
+	var buf bytes.Buffer
+	if err := r.ExportRoutes(&buf, FormatYAML, registry); err != nil {
+		t.Fatal(err)
+	}
+	expected := `routes:
+  - method: GET
+    path: /users/:id
+    handler: getUser
+    middleware:
+      - auth
+    summary: Get user
+    tags:
+      - users
+  - method: GET
+    path: /people/:id
+    handler: getUser
+  - method: DELETE
+    path: /users/:id
+    handler: getUser
+    disabled: true
+  - method: GET
+    path: /hello
+    handler: hello
+    metadata:
+      owner: team
+`
+	if buf.String() != expected {
+		t.Error("Expected", expected, "got", buf.String())
+	}
+
+	// exported routes must be loaded back
+	buf.Reset()
+	if err := r.ExportRoutes(&buf, FormatJSON, registry); err != nil {
+		t.Fatal(err)
+	}
+	loaded := New()
+	if err := loaded.ReadRoutes("export.json", &buf, FormatJSON, registry); err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.routes) != 4 || loaded.routes[3].Meta("owner") != "team" {
+		t.Error("Expected 4 routes with metadata, got", len(loaded.routes))
+	}
+}
+
+func TestRouterReadRoutesConcurrently(t *testing.T) {
+	r := New()
+	wraps := 0
+	r.GET("/hello", func(c *Control) { c.Body("Hello") }).Use(func(next Handle) Handle {
+		wraps++
+		return next
+	})
+	registry := testRegistry()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for idx := 0; idx < 20; idx++ {
+			if err := r.ReadRoutes("routes.yaml", strings.NewReader(testRoutesYAML), FormatYAML, registry); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		if body := testRequest(r, "GET", "/hello", nil).Body.String(); body != "Hello" {
+			t.Error("Expected", "Hello", "got", body)
+		}
+		testRequest(r, "GET", "/users/17", nil)
+	}
+	if wraps != 1 {
+		t.Error("Expected middleware of unchanged route to be composed once, got", wraps)
+	}
+}
@@ -5,11 +5,10 @@
 package router
 
 import (
-	"compress/gzip"
+	"bytes"
 	"context"
-	"encoding/json"
 	"net/http"
-	"strings"
+	"net/url"
 	"time"
 )
 
@@ -21,8 +20,36 @@ const (
 	MIMETEXT = "text/plain"
 )
 
+// Source defines where Control looks for values by name
+type Source uint8
+
+// Sources of values
+const (
+	// SourcePath is a set of path parameters and parameters added by Set
+	SourcePath Source = iota
+	// SourceQuery is a query string of URL
+	SourceQuery
+	// SourceForm is a body of POST, PUT or PATCH form request
+	SourceForm
+	// SourceHeader contains request headers
+	SourceHeader
+	// SourceCookie contains request cookies
+	SourceCookie
+)
+
+// defaultSources is an order of lookup of values if Router.Sources is not set
+var defaultSources = []Source{SourcePath, SourceQuery}
+
+// defaultMaxMemory is a memory limit for parsing of multipart forms
+const defaultMaxMemory = 32 << 20
+
 // Control allows us to pass variables between middleware,
 // assign Http codes and render a Body.
+//
+// Controls created by the router are recycled after the request is served,
+// so a handler must not keep a reference to the Control after it returns,
+// e.g. in a goroutine. Use Copy to pass data of the request outside of the handler.
+// Released Controls are poisoned to catch such mistakes if Router.PoisonControls is set.
 type Control struct {
 
 	// Context embedded
@@ -40,9 +67,6 @@ type Control struct {
 	// Code of HTTP status
 	code int
 
-	// compactJSON propery defines JSON output format (default is not compact)
-	compactJSON bool
-
 	// if used, json header shows meta data
 	useMetaData bool
 
@@ -55,8 +79,47 @@ type Control struct {
 	// params is set of key/value parameters
 	params []Param
 
+	// query contains cached query values
+	query url.Values
+
 	// timer used to calculate a elapsed time for handler and writing it in a response
 	timer time.Time
+
+	// router which serves the request
+	router *Router
+
+	// parts is a buffer of path segments used in matching
+	parts []string
+
+	// released is set for poisoned Controls (see Router.PoisonControls)
+	released bool
+
+	// route which is matched by the request
+	route *Route
+
+	// renderers are used in content negotiation instead of renderers of the route and the router
+	renderers []Renderer
+
+	// format of the response selected by URL suffix
+	format Renderer
+
+	// compact is a mode of JSON responses set by CompactJSON if hasCompact is set
+	compact    bool
+	hasCompact bool
+
+	// events is a stream of server-sent events which is closed with the Control
+	events *EventStream
+
+	// validators of the response set by the handler
+	etag         string
+	lastModified time.Time
+
+	// page of a collection requested by query parameters
+	page *Page
+
+	// data contains fields of the data member of the metadata which are set by the handler
+	data    Data
+	useData bool
 }
 
 // Param is a URL parameter which represents as key and value.
@@ -98,24 +161,194 @@ type Error struct {
 
 // Get returns the first value associated with the given name.
 // If there are no values associated with the key, an empty string is returned.
+// Sources of values are checked in order defined in Router.Sources.
 func (c *Control) Get(name string) string {
+	c.checkReleased()
+	for _, source := range c.sources() {
+		if source == SourcePath {
+			// path params are checked without allocations
+			for idx := range c.params {
+				if matchParamKey(c.params[idx].Key, name) {
+					return c.params[idx].Value
+				}
+			}
+			continue
+		}
+		if values := c.lookup(source, name); len(values) > 0 {
+			return values[0]
+		}
+	}
+
+	return ""
+}
+
+// GetAll returns all values associated with the given name from all sources
+func (c *Control) GetAll(name string) []string {
+	c.checkReleased()
+	var result []string
+	for _, source := range c.sources() {
+		result = append(result, c.lookup(source, name)...)
+	}
+
+	return result
+}
+
+// Has checks whether the name is present in any of sources, even with empty value
+func (c *Control) Has(name string) bool {
+	c.checkReleased()
+	for _, source := range c.sources() {
+		if c.lookup(source, name) != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Query returns query values which are parsed once per request
+func (c *Control) Query() url.Values {
+	c.checkReleased()
+	if c.query == nil {
+		if c.Request == nil {
+			c.query = make(url.Values)
+		} else {
+			c.query, _ = url.ParseQuery(c.Request.URL.RawQuery)
+		}
+	}
+	return c.query
+}
+
+// sources returns order of lookup of values
+func (c *Control) sources() []Source {
+	if c.router != nil && len(c.router.Sources) > 0 {
+		return c.router.Sources
+	}
+	return defaultSources
+}
+
+// lookup returns values of the name from the source, or nil if the name is absent
+func (c *Control) lookup(source Source, name string) []string {
+	switch source {
+	case SourcePath:
+		var values []string
+		for idx := range c.params {
+			if matchParamKey(c.params[idx].Key, name) {
+				values = append(values, c.params[idx].Value)
+			}
+		}
+		return values
+	case SourceQuery:
+		if c.Request == nil {
+			return nil
+		}
+		return c.Query()[name]
+	case SourceForm:
+		if c.Request == nil {
+			return nil
+		}
+		if c.Request.PostForm == nil {
+			c.Request.ParseMultipartForm(defaultMaxMemory)
+		}
+		return c.Request.PostForm[name]
+	case SourceHeader:
+		if c.Request == nil {
+			return nil
+		}
+		return c.Request.Header[http.CanonicalHeaderKey(name)]
+	case SourceCookie:
+		if c.Request == nil {
+			return nil
+		}
+		var values []string
+		for _, cookie := range c.Request.Cookies() {
+			if cookie.Name == name {
+				values = append(values, cookie.Value)
+			}
+		}
+		return values
+	}
+
+	return nil
+}
+
+// Param returns a value of the path parameter by its name without prefix,
+// e.g. Param("name") for the route "/hello/:name". The query string is not used.
+func (c *Control) Param(name string) string {
+	c.checkReleased()
 	for idx := range c.params {
-		if c.params[idx].Key == name {
+		if bareParamKey(c.params[idx].Key) == name {
 			return c.params[idx].Value
 		}
 	}
 
-	return c.Request.URL.Query().Get(name)
+	return ""
+}
+
+// Params returns a read-only view of the path parameters in order of the route pattern.
+// The view is valid until the handler returns.
+func (c *Control) Params() Params {
+	c.checkReleased()
+	return Params{params: c.params}
+}
+
+// Params is a read-only ordered view of parameters
+type Params struct {
+	params []Param
+}
+
+// Len returns a number of parameters
+func (p Params) Len() int {
+	return len(p.params)
+}
+
+// At returns the parameter by its index
+func (p Params) At(idx int) Param {
+	return p.params[idx]
+}
+
+// Get returns a value of the parameter by its name without prefix
+// and reports whether the parameter is present
+func (p Params) Get(name string) (string, bool) {
+	for idx := range p.params {
+		if bareParamKey(p.params[idx].Key) == name {
+			return p.params[idx].Value, true
+		}
+	}
+	return "", false
+}
+
+// Slice returns a copy of parameters
+func (p Params) Slice() []Param {
+	return append([]Param(nil), p.params...)
+}
+
+// bareParamKey returns the key without ":" or "*" prefix
+func bareParamKey(key string) string {
+	if len(key) > 1 && (key[0] == ':' || key[0] == '*') {
+		return key[1:]
+	}
+	return key
+}
+
+// matchParamKey checks whether the key matches the name, the name may have ":" or "*"
+// prefix for keys which are stored without prefix (see Router.BareParams)
+func matchParamKey(key, name string) bool {
+	if key == name {
+		return true
+	}
+	return len(name) == len(key)+1 && (name[0] == ':' || name[0] == '*') && name[1:] == key
 }
 
 // Set adds new parameters which represents as set of key/value.
 func (c *Control) Set(params ...Param) *Control {
+	c.checkReleased()
 	c.params = append(c.params, params...)
 	return c
 }
 
 // Code assigns http status code, which returns on http request
 func (c *Control) Code(code int) *Control {
+	c.checkReleased()
 	if code >= 200 && code < 600 {
 		c.code = code
 	}
@@ -124,23 +357,37 @@ func (c *Control) Code(code int) *Control {
 
 // GetCode returns status code
 func (c *Control) GetCode() int {
+	c.checkReleased()
 	return c.code
 }
 
-// CompactJSON changes JSON output format (default mode is false)
+// CompactJSON changes JSON output format (default mode is false),
+// JSON responses are rendered by JSONRenderer with Compact option
 func (c *Control) CompactJSON(mode bool) *Control {
-	c.compactJSON = mode
+	c.checkReleased()
+	c.compact = mode
+	c.hasCompact = true
+	return c
+}
+
+// Renderers sets renderers which are offered in content negotiation of Body
+// instead of renderers of the route and the router
+func (c *Control) Renderers(renderers ...Renderer) *Control {
+	c.checkReleased()
+	c.renderers = renderers
 	return c
 }
 
 // UseMetaData shows meta data in JSON Header
 func (c *Control) UseMetaData() *Control {
+	c.checkReleased()
 	c.useMetaData = true
 	return c
 }
 
 // APIVersion adds API version meta data
 func (c *Control) APIVersion(version string) *Control {
+	c.checkReleased()
 	c.useMetaData = true
 	c.header.APIVersion = version
 	return c
@@ -148,6 +395,7 @@ func (c *Control) APIVersion(version string) *Control {
 
 // HeaderContext adds context meta data
 func (c *Control) HeaderContext(context string) *Control {
+	c.checkReleased()
 	c.useMetaData = true
 	c.header.Context = context
 	return c
@@ -155,6 +403,7 @@ func (c *Control) HeaderContext(context string) *Control {
 
 // ID adds id meta data
 func (c *Control) ID(id string) *Control {
+	c.checkReleased()
 	c.useMetaData = true
 	c.header.ID = id
 	return c
@@ -162,6 +411,7 @@ func (c *Control) ID(id string) *Control {
 
 // Method adds method meta data
 func (c *Control) Method(method string) *Control {
+	c.checkReleased()
 	c.useMetaData = true
 	c.header.Method = method
 	return c
@@ -169,6 +419,7 @@ func (c *Control) Method(method string) *Control {
 
 // SetParams adds params meta data in alternative format
 func (c *Control) SetParams(params interface{}) *Control {
+	c.checkReleased()
 	c.useMetaData = true
 	c.header.Params = params
 	return c
@@ -176,6 +427,7 @@ func (c *Control) SetParams(params interface{}) *Control {
 
 // SetError sets error code and error message
 func (c *Control) SetError(code uint16, message string) *Control {
+	c.checkReleased()
 	c.useMetaData = true
 	c.errorHeader.Code = code
 	c.errorHeader.Message = message
@@ -184,6 +436,7 @@ func (c *Control) SetError(code uint16, message string) *Control {
 
 // AddError adds new error
 func (c *Control) AddError(errors ...Error) *Control {
+	c.checkReleased()
 	c.useMetaData = true
 	c.errorHeader.Errors = append(c.errorHeader.Errors, errors...)
 	return c
@@ -191,17 +444,39 @@ func (c *Control) AddError(errors ...Error) *Control {
 
 // UseTimer allows caalculate elapsed time of request handling
 func (c *Control) UseTimer() {
+	c.checkReleased()
 	c.useMetaData = true
 	c.timer = time.Now()
 }
 
 // GetTimer returns timer state
 func (c *Control) GetTimer() time.Time {
+	c.checkReleased()
 	return c.timer
 }
 
-// Body renders the given data into the response body
+// metaData returns the header with meta data which contains the data
+func (c *Control) metaData(data interface{}) Header {
+	c.header.Data = data
+	if !c.timer.IsZero() {
+		took := time.Now()
+		c.header.Duration = took.Sub(c.timer)
+		c.header.Took = took.Sub(c.timer).String()
+	}
+	if c.header.Params == nil && len(c.params) > 0 {
+		c.header.Params = c.params
+	}
+	if c.errorHeader.Code != 0 || c.errorHeader.Message != "" || len(c.errorHeader.Errors) > 0 {
+		c.header.Error = c.errorHeader
+	}
+	return c.header
+}
+
+// Body renders the given data into the response body. Strings are rendered as is,
+// other data is rendered by a Renderer which is negotiated by "Accept" header,
+// by default JSON, XML, YAML, CSV or URL encoded form.
 func (c *Control) Body(data interface{}) {
+	c.checkReleased()
 	var content []byte
 
 	if str, ok := data.(string); ok {
@@ -212,45 +487,67 @@ func (c *Control) Body(data interface{}) {
 			c.Writer.Header().Add("Content-type", MIMETEXT)
 		}
 	} else {
+		if c.page != nil && c.Request != nil {
+			c.data.PreviousLink, c.data.NextLink = c.pageLinks(itemCount(data))
+		}
 		if c.useMetaData {
-			c.header.Data = data
-			if !c.timer.IsZero() {
-				took := time.Now()
-				c.header.Duration = took.Sub(c.timer)
-				c.header.Took = took.Sub(c.timer).String()
+			if c.page != nil || c.useData {
+				data = c.itemData(data)
 			}
-			if c.header.Params == nil && len(c.params) > 0 {
-				c.header.Params = c.params
-			}
-			if c.errorHeader.Code != 0 || c.errorHeader.Message != "" || len(c.errorHeader.Errors) > 0 {
-				c.header.Error = c.errorHeader
-			}
-			data = c.header
+			data = c.metaData(data)
 		}
-		var err error
-		if c.compactJSON {
-			content, err = json.Marshal(data)
-		} else {
-			content, err = json.MarshalIndent(data, "", "  ")
+		renderer := c.renderer()
+		if renderer == nil {
+			c.notAcceptable()
+			return
 		}
-		if err != nil {
-			c.Writer.WriteHeader(http.StatusInternalServerError)
+		var buf bytes.Buffer
+		if err := renderer.Render(&buf, data); err != nil {
+			c.fail(OpRender, err)
 			return
 		}
-		c.Writer.Header().Add("Content-type", MIMEJSON)
+		content = buf.Bytes()
+		c.Writer.Header().Add("Content-type", renderer.ContentType())
+		// the format depends on "Accept" header only if it is negotiated between several renderers
+		if c.format == nil && len(c.renderList()) > 1 {
+			addVary(c.Writer.Header(), "Accept")
+		}
+	}
+	c.write(content)
+}
+
+// write writes the rendered content with validators and compression
+func (c *Control) write(content []byte) {
+	if c.validate(content) {
+		return
 	}
-	if strings.Contains(c.Request.Header.Get("Accept-Encoding"), "gzip") {
-		c.Writer.Header().Add("Content-Encoding", "gzip")
+	encoding := c.contentEncoding(len(content))
+	if encoding == "" {
 		if c.code > 0 {
 			c.Writer.WriteHeader(c.code)
 		}
-		gz := gzip.NewWriter(c.Writer)
-		gz.Write(content)
-		gz.Close()
-	} else {
-		if c.code > 0 {
-			c.Writer.WriteHeader(c.code)
+		if _, err := c.Writer.Write(content); err != nil {
+			c.fail(OpWrite, err)
 		}
-		c.Writer.Write(content)
+		return
+	}
+	c.Writer.Header().Set("Content-Encoding", encoding)
+	c.Writer.Header().Del("Content-Length")
+	// compressed content is not byte-for-byte the same as the content of ETag
+	if etag := c.Writer.Header().Get("ETag"); etag != "" {
+		c.Writer.Header().Set("ETag", weakETag(etag))
+	}
+	if c.code > 0 {
+		c.Writer.WriteHeader(c.code)
+	}
+	cmp := c.compression()
+	cw := cmp.writer(encoding, c.Writer)
+	_, err := cw.Write(content)
+	if closeErr := cw.Close(); err == nil {
+		err = closeErr
+	}
+	cmp.release(encoding, cw)
+	if err != nil {
+		c.fail(OpWrite, err)
 	}
 }
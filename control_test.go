@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -169,3 +170,77 @@ func TestControlBody(t *testing.T) {
 		t.Error("Expected", testParamsData, "got", trw.Body.String())
 	}
 }
+
+func TestControlQueryValues(t *testing.T) {
+	req, err := http.NewRequest("GET", "/users?tag=a&tag=b&empty=", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	c := &Control{Request: req}
+	c.Set(Param{Key: "tag", Value: "path"})
+	if c.Get("tag") != "path" {
+		t.Error("Expected", "path", "got", c.Get("tag"))
+	}
+	if all := c.GetAll("tag"); strings.Join(all, ",") != "path,a,b" {
+		t.Error("Expected", "path,a,b", "got", all)
+	}
+	if !c.Has("empty") || c.Has("missing") {
+		t.Error("Expected empty value to be present and missing value to be absent")
+	}
+	// query is parsed once per request
+	req.URL.RawQuery = "tag=c"
+	if all := c.Query()["tag"]; strings.Join(all, ",") != "a,b" {
+		t.Error("Expected", "a,b", "got", all)
+	}
+}
+
+func TestControlSources(t *testing.T) {
+	r := New()
+	r.Sources = []Source{SourceHeader, SourceCookie, SourceForm, SourceQuery, SourcePath}
+	r.POST("/users/:name", func(c *Control) {
+		c.Body(strings.Join([]string{
+			c.Get("X-Tenant"), c.Get("session"), c.Get("name"), c.Get(":name"), c.Get("page"),
+		}, " "))
+	})
+	req, err := http.NewRequest("POST", "/users/john?page=2&name=query", strings.NewReader("name=form"))
+	if err != nil {
+		t.Error(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Tenant", "acme")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "s1"})
+	trw := httptest.NewRecorder()
+	r.ServeHTTP(trw, req)
+	if trw.Body.String() != "acme s1 form john 2" {
+		t.Error("Expected", "acme s1 form john 2", "got", trw.Body.String())
+	}
+}
+
+func TestControlParam(t *testing.T) {
+	req, err := http.NewRequest("GET", "/files/css/app.css?dir=query", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	c := &Control{Request: req}
+	c.Set(Param{Key: ":dir", Value: "css"}, Param{Key: "*filepath", Value: "app.css"})
+	if c.Param("dir") != "css" || c.Param("filepath") != "app.css" {
+		t.Error("Expected", "css app.css", "got", c.Param("dir"), c.Param("filepath"))
+	}
+	if c.Param("missing") != "" || c.Get("dir") != "query" {
+		t.Error("Expected path parameters only in Param, got", c.Param("missing"), c.Get("dir"))
+	}
+	params := c.Params()
+	if params.Len() != 2 || params.At(1).Key != "*filepath" {
+		t.Error("Unexpected params", params.Slice())
+	}
+	if value, ok := params.Get("dir"); !ok || value != "css" {
+		t.Error("Expected", "css", "got", value)
+	}
+	if _, ok := params.Get("query"); ok {
+		t.Error("Unexpected param query")
+	}
+	params.Slice()[0].Value = "changed"
+	if c.Param("dir") != "css" {
+		t.Error("Expected read-only params, got", c.Param("dir"))
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2015 Igor Dolzhikov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+)
+
+// Operations of Control.Body which may fail
+const (
+	// OpRender is encoding of data by a Renderer
+	OpRender = "render"
+	// OpWrite is writing of the response to the client
+	OpWrite = "write"
+	// OpPrecondition is fetching of the resource state for preconditions of the route
+	OpPrecondition = "precondition"
+)
+
+// ResponseError is passed to Router.ErrorHandler when Control.Body fails
+// or the state of the resource for preconditions cannot be fetched
+type ResponseError struct {
+	// Op is OpRender, OpWrite or OpPrecondition
+	Op  string
+	Err error
+}
+
+func (e *ResponseError) Error() string {
+	return "router: " + e.Op + " response: " + e.Err.Error()
+}
+
+// Unwrap returns the original error
+func (e *ResponseError) Unwrap() error {
+	return e.Err
+}
+
+// fail passes the error to Router.ErrorHandler or handles it by default,
+// the text of the error is shown to the client in development mode only
+func (c *Control) fail(op string, err error) {
+	err = &ResponseError{Op: op, Err: err}
+	if c.router != nil && c.router.ErrorHandler != nil {
+		c.router.ErrorHandler(c, err)
+		return
+	}
+	c.logError(err)
+	if op == OpWrite {
+		return
+	}
+	report := Error{Reason: "internalError", Message: http.StatusText(http.StatusInternalServerError)}
+	if c.router != nil && c.router.Development {
+		report.Message = err.Error()
+	}
+	c.renderError(http.StatusInternalServerError, report)
+}
+
+// logError writes the error with the request into Router.ErrorLog or the standard logger
+func (c *Control) logError(err error) {
+	logger := log.Default()
+	if c.router != nil && c.router.ErrorLog != nil {
+		logger = c.router.ErrorLog
+	}
+	if c.Request != nil {
+		logger.Println(c.Request.Method, c.Request.URL.Path, err)
+	} else {
+		logger.Println(err)
+	}
+}
+
+// renderError writes the ErrorHeader envelope with the report in the negotiated format
+func (c *Control) renderError(code int, report Error) {
+	errorHeader := ErrorHeader{Code: uint16(code), Message: http.StatusText(code), Errors: []Error{report}}
+	header := Header{
+		APIVersion: c.header.APIVersion,
+		Context:    c.header.Context,
+		ID:         c.header.ID,
+		Method:     c.header.Method,
+		Error:      errorHeader,
+	}
+	var buf bytes.Buffer
+	renderer := c.renderer()
+	if renderer == nil || renderer.Render(&buf, header) != nil {
+		http.Error(c.Writer, http.StatusText(code), code)
+		return
+	}
+	c.Writer.Header().Set("Content-type", renderer.ContentType())
+	c.Writer.Header().Del("Content-Length")
+	c.Writer.WriteHeader(code)
+	c.Writer.Write(buf.Bytes())
+}
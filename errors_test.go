@@ -0,0 +1,84 @@
+package router
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// failingWriter fails to write the body like a closed connection
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestControlBodyRenderError(t *testing.T) {
+	var logs bytes.Buffer
+	r := New()
+	r.Renderers = BuiltinRenderers()
+	r.ErrorLog = log.New(&logs, "", 0)
+	r.GET("/fail", func(c *Control) {
+		c.APIVersion("1.0").Body(map[string]interface{}{"fn": func() {}})
+	})
+
+	trw := testRequest(r, "GET", "/fail", nil)
+	if trw.Code != http.StatusInternalServerError || trw.Header().Get("Content-type") != MIMEJSON {
+		t.Error("Expected", http.StatusInternalServerError, MIMEJSON, "got", trw.Code, trw.Header().Get("Content-type"))
+	}
+	if body := trw.Body.String(); !strings.Contains(body, `"apiVersion": "1.0"`) ||
+		!strings.Contains(body, `"code": 500`) || strings.Contains(body, "unsupported type") {
+		t.Error("Expected error envelope without details, got", body)
+	}
+	if !strings.Contains(logs.String(), "GET /fail router: render response: json: unsupported type") {
+		t.Error("Expected logged error, got", logs.String())
+	}
+
+	trw = testRequest(r, "GET", "/fail", map[string]string{"Accept": MIMEXML})
+	if trw.Code != http.StatusInternalServerError || !strings.Contains(trw.Body.String(), "<code>500</code>") {
+		t.Error("Expected XML error envelope, got", trw.Code, trw.Body.String())
+	}
+
+	r.Development = true
+	if trw = testRequest(r, "GET", "/fail", nil); !strings.Contains(trw.Body.String(), "unsupported type") {
+		t.Error("Expected error details in development mode, got", trw.Body.String())
+	}
+}
+
+func TestControlBodyErrorHandler(t *testing.T) {
+	var errs []error
+	r := New()
+	r.ErrorHandler = func(c *Control, err error) {
+		errs = append(errs, err)
+		c.Writer.WriteHeader(http.StatusTeapot)
+	}
+	r.GET("/fail", func(c *Control) {
+		c.Body(func() {})
+	})
+	r.GET("/write", func(c *Control) {
+		c.Body(map[string]string{"status": "ok"})
+	})
+
+	if trw := testRequest(r, "GET", "/fail", nil); trw.Code != http.StatusTeapot {
+		t.Error("Expected", http.StatusTeapot, "got", trw.Code)
+	}
+	req, _ := http.NewRequest("GET", "/write", nil)
+	r.ServeHTTP(failingWriter{httptest.NewRecorder()}, req)
+
+	if len(errs) != 2 {
+		t.Fatal("Expected 2 errors, got", errs)
+	}
+	var responseErr *ResponseError
+	if !errors.As(errs[0], &responseErr) || responseErr.Op != OpRender {
+		t.Error("Expected", OpRender, "got", errs[0])
+	}
+	if !errors.As(errs[1], &responseErr) || responseErr.Op != OpWrite || responseErr.Err.Error() != "broken pipe" {
+		t.Error("Expected", OpWrite, "got", errs[1])
+	}
+}
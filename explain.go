@@ -0,0 +1,154 @@
+// Copyright 2015 Igor Dolzhikov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MatchHeader is a response header which shows matched pattern in development mode
+const MatchHeader = "X-Router-Match"
+
+// Candidate is a route which was tried to match a request
+type Candidate struct {
+	Method  string
+	Pattern string
+	Matched bool
+	// Reason describes why the candidate was rejected or accepted
+	Reason string
+}
+
+// Explanation contains ordered list of candidates which were tried to match a request
+type Explanation struct {
+	Method     string
+	Path       string
+	Candidates []Candidate
+	// Match is a winner candidate, nil if request is not matched
+	Match  *Candidate
+	Params []Param
+	// Format is a content type selected by the suffix of the path (see Router.FormatSuffix)
+	Format string
+}
+
+// Explain returns ordered list of candidates which were tried to match the method and the path,
+// reasons of rejection of each of them and the winner
+func (r *Router) Explain(method, path string) *Explanation {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e := &Explanation{Method: method, Path: path}
+	if parser := r.handlers[method]; parser != nil {
+		// the path is matched without the format suffix as in ServeHTTP
+		if _, _, _, format, ok := r.lookup(parser, path, nil, nil); ok && format != nil {
+			path, _ = suffixRenderer(path, r.renderList())
+			e.Format = format.ContentType()
+		}
+		e.Candidates = parser.explain(method, path)
+	} else {
+		e.Candidates = append(e.Candidates, Candidate{
+			Method: method,
+			Reason: fmt.Sprintf("no routes registered for method %s", method),
+		})
+	}
+	for idx := range e.Candidates {
+		if e.Candidates[idx].Matched {
+			e.Match = &e.Candidates[idx]
+			_, e.Params, _ = r.handlers[method].get(path)
+			if r.BareParams {
+				trimParamKeys(e.Params)
+			}
+			return e
+		}
+	}
+	// collect routes which match the path with another method
+	var methods []string
+	for m := range r.handlers {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+	for _, m := range methods {
+		if m == method {
+			continue
+		}
+		if rec, _, ok := r.handlers[m].match(path); ok {
+			e.Candidates = append(e.Candidates, Candidate{
+				Method:  m,
+				Pattern: rec.pattern,
+				Reason:  fmt.Sprintf("method mismatch: route accepts %s, request has %s", m, method),
+			})
+		}
+	}
+
+	return e
+}
+
+// explain repeats the steps of the match with recording of every tried candidate
+func (p *parser) explain(method, path string) []Candidate {
+	var candidates []Candidate
+	if rec, ok := p.static[asterisk]; ok {
+		return append(candidates, Candidate{
+			Method: method, Pattern: rec.pattern, Matched: true, Reason: "catch-all route matches any path",
+		})
+	}
+	if rec, ok := p.static[path]; ok {
+		return append(candidates, Candidate{
+			Method: method, Pattern: rec.pattern, Matched: true, Reason: "static route matches exactly",
+		})
+	}
+	parts, ok := split(path)
+	if !ok {
+		return append(candidates, Candidate{
+			Method: method, Reason: fmt.Sprintf("path has more than %d segments", maxLevel-1),
+		})
+	}
+	normalized := "/" + join(parts)
+	if rec, ok := p.static[normalized]; ok {
+		return append(candidates, Candidate{
+			Method: method, Pattern: rec.pattern, Matched: true, Reason: "static route matches normalized path",
+		})
+	}
+	candidates = append(candidates, Candidate{
+		Method: method, Pattern: normalized, Reason: "no static route",
+	})
+	for _, rec := range p.fields[uint8(len(parts))] {
+		candidate := explainRecord(method, rec, parts)
+		candidates = append(candidates, candidate)
+		if candidate.Matched {
+			return candidates
+		}
+	}
+	for _, rec := range p.wildcard {
+		candidate := explainRecord(method, rec, parts)
+		candidates = append(candidates, candidate)
+		if candidate.Matched {
+			return candidates
+		}
+	}
+
+	return candidates
+}
+
+func explainRecord(method string, rec *record, parts []string) Candidate {
+	candidate := Candidate{Method: method, Pattern: rec.pattern}
+	for idx, value := range rec.parts {
+		if len(value) >= 1 && value[0:1] == asterisk {
+			candidate.Matched = true
+			candidate.Reason = fmt.Sprintf("wildcard matches the rest of path from segment %d", idx+1)
+			return candidate
+		}
+		if idx >= len(parts) {
+			candidate.Reason = fmt.Sprintf("path is shorter than pattern at segment %d", idx+1)
+			return candidate
+		}
+		if value != parts[idx] && !(len(value) >= 1 && value[0:1] == ":") {
+			candidate.Reason = fmt.Sprintf("literal mismatch at segment %d: expected %q, got %q", idx+1, value, parts[idx])
+			return candidate
+		}
+	}
+	candidate.Matched = true
+	candidate.Reason = "all segments match"
+
+	return candidate
+}
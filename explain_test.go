@@ -0,0 +1,106 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouterExplain(t *testing.T) {
+	r := New()
+	r.GET("/users/me", func(c *Control) {})
+	r.GET("/users/:id/posts", func(c *Control) {})
+	r.GET("/users/:id", func(c *Control) {})
+	r.GET("/files/*", func(c *Control) {})
+	r.POST("/groups/:id", func(c *Control) {})
+
+	e := r.Explain("GET", "/users/me")
+	if e.Match == nil || e.Match.Pattern != "/users/me" || len(e.Candidates) != 1 {
+		t.Error("Expected static match /users/me, got", e.Candidates)
+	}
+
+	e = r.Explain("GET", "/users/17")
+	if e.Match == nil || e.Match.Pattern != "/users/:id" {
+		t.Fatal("Expected match /users/:id, got", e.Candidates)
+	}
+	if len(e.Params) != 1 || e.Params[0].Value != "17" {
+		t.Error("Expected param 17, got", e.Params)
+	}
+
+	e = r.Explain("GET", "/files/css/app.css")
+	if e.Match == nil || e.Match.Pattern != "/files/*" {
+		t.Fatal("Expected match /files/*, got", e.Candidates)
+	}
+
+	e = r.Explain("GET", "/users/17/comments")
+	if e.Match != nil {
+		t.Error("Unexpected match", e.Match)
+	}
+	var reasons []string
+	for _, candidate := range e.Candidates {
+		reasons = append(reasons, candidate.Pattern+": "+candidate.Reason)
+	}
+	expected := []string{
+		"/users/17/comments: no static route",
+		`/users/:id/posts: literal mismatch at segment 3: expected "posts", got "comments"`,
+		`/files/*: literal mismatch at segment 1: expected "files", got "users"`,
+	}
+	if strings.Join(reasons, "\n") != strings.Join(expected, "\n") {
+		t.Error("Expected", expected, "got", reasons)
+	}
+
+	e = r.Explain("GET", "/groups/1")
+	last := e.Candidates[len(e.Candidates)-1]
+	if last.Method != "POST" || !strings.HasPrefix(last.Reason, "method mismatch") {
+		t.Error("Expected method mismatch for POST, got", last)
+	}
+
+	e = r.Explain("PUT", "/users/1")
+	if len(e.Candidates) != 2 || !strings.HasPrefix(e.Candidates[0].Reason, "no routes registered") {
+		t.Error("Expected missing method, got", e.Candidates)
+	}
+}
+
+func TestRouterMatchHeader(t *testing.T) {
+	r := New()
+	r.GET("/users/:id", func(c *Control) {})
+
+	req, err := http.NewRequest("GET", "/users/17", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	trw := httptest.NewRecorder()
+	r.ServeHTTP(trw, req)
+	if trw.Header().Get(MatchHeader) != "" {
+		t.Error("Unexpected header", MatchHeader, "in production mode")
+	}
+	r.Development = true
+	trw = httptest.NewRecorder()
+	r.ServeHTTP(trw, req)
+	if trw.Header().Get(MatchHeader) != "/users/:id" {
+		t.Error("Expected", "/users/:id", "got", trw.Header().Get(MatchHeader))
+	}
+}
+
+func TestRouterExplainFormatSuffix(t *testing.T) {
+	r := New()
+	r.FormatSuffix = true
+	r.GET("/users/:id", func(c *Control) {})
+	r.GET("/files/*filepath", func(c *Control) {})
+
+	e := r.Explain("GET", "/users/1.json")
+	if e.Match == nil || e.Match.Pattern != "/users/:id" || e.Format != MIMEJSON {
+		t.Fatal("Expected match /users/:id in JSON, got", e.Candidates, e.Format)
+	}
+	if len(e.Params) != 1 || e.Params[0].Value != "1" {
+		t.Error("Expected param 1, got", e.Params)
+	}
+	if e = r.Explain("GET", "/files/data.json"); e.Format != "" || e.Params[0].Value != "data.json" {
+		t.Error("Expected file data.json without format, got", e.Params, e.Format)
+	}
+	r.FormatSuffix = false
+	if e = r.Explain("GET", "/users/1.json"); e.Format != "" || e.Params[0].Value != "1.json" {
+		t.Error("Expected param 1.json, got", e.Params, e.Format)
+	}
+}
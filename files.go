@@ -0,0 +1,284 @@
+// Copyright 2015 Igor Dolzhikov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Listing defines format of directory listings
+type Listing int
+
+// Formats of directory listings
+const (
+	// ListingNone disables directory listings
+	ListingNone Listing = iota
+	// ListingJSON shows directory content in JSON format
+	ListingJSON
+	// ListingHTML shows directory content as HTML page
+	ListingHTML
+)
+
+// FileServer serves files from a file system, e.g. embed.FS or os.DirFS
+type FileServer struct {
+	// FS is a file system with served files
+	FS fs.FS
+
+	// Param is a name of catch-all parameter which contains file path
+	Param string
+
+	// Index contains names of files which are served instead of directory
+	Index []string
+
+	// Listing defines format of directory listings if there is no index file
+	Listing Listing
+
+	// Precompressed allows to serve "name.gz" file instead of "name"
+	// if client accepts gzip encoding
+	Precompressed bool
+
+	// NotFound is called when a file not found.
+	// If it is not set, NotFound handler of the router is used.
+	NotFound Handle
+
+	router *Router
+
+	// etags contains content hashes of served files
+	etags sync.Map
+}
+
+// FileInfo is an item of directory listing
+type FileInfo struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime,omitempty"`
+	IsDir   bool      `json:"isDir,omitempty"`
+}
+
+// fileETag is a cached ETag of a file
+type fileETag struct {
+	size    int64
+	modTime time.Time
+	etag    string
+}
+
+// ServeFiles serves files from the file system. The path must end with catch-all
+// parameter, e.g. "/static/*filepath", which contains a path of the file in the file system.
+// The file server is returned to adjust its options.
+func (r *Router) ServeFiles(path string, fsys fs.FS) *FileServer {
+	parts, _ := split(path)
+	if len(parts) == 0 || len(parts[len(parts)-1]) < 2 || parts[len(parts)-1][0:1] != asterisk {
+		panic("router: path must end with catch-all parameter like /*filepath in path '" + path + "'")
+	}
+	server := &FileServer{
+		FS:            fsys,
+		Param:         parts[len(parts)-1],
+		Index:         []string{"index.html"},
+		Precompressed: true,
+		router:        r,
+	}
+	r.GET(path, server.Serve)
+	r.HEAD(path, server.Serve)
+
+	return server
+}
+
+// Serve is a handler which serves the file requested in the catch-all parameter
+func (s *FileServer) Serve(c *Control) {
+	name, ok := cleanFilePath(c.Param(bareParamKey(s.Param)))
+	if !ok {
+		c.Code(http.StatusBadRequest).Body(http.StatusText(http.StatusBadRequest))
+		return
+	}
+	s.serveName(c, name)
+}
+
+// serveName serves a file or a directory by the name in the file system
+func (s *FileServer) serveName(c *Control, name string) {
+	info, err := fs.Stat(s.FS, name)
+	if err != nil {
+		s.notFound(c)
+		return
+	}
+	if !info.IsDir() {
+		s.serveFile(c, name, info)
+		return
+	}
+	// directory must be requested with trailing slash to have correct relative links
+	if !strings.HasSuffix(c.Request.URL.Path, "/") {
+		target := path.Base(c.Request.URL.Path) + "/"
+		if c.Request.URL.RawQuery != "" {
+			target += "?" + c.Request.URL.RawQuery
+		}
+		c.Writer.Header().Set("Location", target)
+		c.Writer.WriteHeader(http.StatusMovedPermanently)
+		return
+	}
+	for _, index := range s.Index {
+		indexName := path.Join(name, index)
+		if info, err := fs.Stat(s.FS, indexName); err == nil && !info.IsDir() {
+			s.serveFile(c, indexName, info)
+			return
+		}
+	}
+	if s.Listing == ListingNone {
+		s.notFound(c)
+		return
+	}
+	s.serveListing(c, name)
+}
+
+// cleanFilePath converts requested path into a valid path of file system,
+// it returns false if the path tries to leave the root
+func cleanFilePath(name string) (string, bool) {
+	if strings.ContainsAny(name, "\\\x00") {
+		return "", false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", false
+		}
+	}
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		name = "."
+	}
+	return name, fs.ValidPath(name)
+}
+
+func (s *FileServer) notFound(c *Control) {
+	switch {
+	case s.NotFound != nil:
+		s.NotFound(c)
+	case s.router != nil && s.router.NotFound != nil:
+		s.router.NotFound(c)
+	default:
+		http.NotFound(c.Writer, c.Request)
+	}
+}
+
+// serveFile writes content of the file with support of conditional and range requests
+func (s *FileServer) serveFile(c *Control, name string, info fs.FileInfo) {
+	header := c.Writer.Header()
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if s.Precompressed && negotiateEncoding(c.Request.Header.Get("Accept-Encoding")) == encodingGzip {
+		if gzInfo, err := fs.Stat(s.FS, name+".gz"); err == nil && !gzInfo.IsDir() {
+			header.Add("Vary", "Accept-Encoding")
+			header.Set("Content-Encoding", "gzip")
+			if contentType == "" {
+				contentType = "application/octet-stream"
+			}
+			name, info = name+".gz", gzInfo
+		}
+	}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	content, err := s.open(name)
+	if err != nil {
+		s.notFound(c)
+		return
+	}
+	defer content.Close()
+	if etag, err := s.etag(name, info, content); err == nil {
+		header.Set("Etag", etag)
+	}
+	http.ServeContent(c.Writer, c.Request, name, info.ModTime(), content)
+}
+
+// readSeekCloser allows to serve files which do not support seeking
+type readSeekCloser struct {
+	*bytes.Reader
+}
+
+func (readSeekCloser) Close() error { return nil }
+
+// open returns a file which supports seeking
+func (s *FileServer) open(name string) (io.ReadSeekCloser, error) {
+	file, err := s.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if content, ok := file.(io.ReadSeekCloser); ok {
+		return content, nil
+	}
+	defer file.Close()
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	return readSeekCloser{bytes.NewReader(data)}, nil
+}
+
+// etag returns a strong ETag based on a content hash which is cached
+// until size or modification time of the file is changed
+func (s *FileServer) etag(name string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	if cached, ok := s.etags.Load(name); ok {
+		if e := cached.(fileETag); e.size == info.Size() && e.modTime.Equal(info.ModTime()) {
+			return e.etag, nil
+		}
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+	s.etags.Store(name, fileETag{size: info.Size(), modTime: info.ModTime(), etag: etag})
+
+	return etag, nil
+}
+
+// serveListing shows content of the directory
+func (s *FileServer) serveListing(c *Control, name string) {
+	entries, err := fs.ReadDir(s.FS, name)
+	if err != nil {
+		s.notFound(c)
+		return
+	}
+	list := make([]FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		list = append(list, FileInfo{Name: entry.Name(), Size: info.Size(), ModTime: info.ModTime(), IsDir: entry.IsDir()})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	if s.Listing == ListingJSON {
+		c.Body(list)
+		return
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<!doctype html>\n<title>%s</title>\n<pre>\n", html.EscapeString(c.Request.URL.Path))
+	for _, info := range list {
+		entryName := info.Name
+		if info.IsDir {
+			entryName += "/"
+		}
+		link := url.URL{Path: entryName}
+		fmt.Fprintf(&buf, "<a href=\"%s\">%s</a>\n", link.String(), html.EscapeString(entryName))
+	}
+	buf.WriteString("</pre>\n")
+	c.ContentType = "text/html; charset=utf-8"
+	c.Body(buf.String())
+}
@@ -0,0 +1,145 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var testFS = fstest.MapFS{
+	"index.html":         {Data: []byte("<h1>Home</h1>"), ModTime: time.Unix(1500000000, 0)},
+	"css/app.css":        {Data: []byte("body { color: red; }")},
+	"js/app.js":          {Data: []byte("console.log('plain')")},
+	"js/app.js.gz":       {Data: []byte("gzipped")},
+	"docs/readme.txt":    {Data: []byte("0123456789")},
+	"docs/guide/one.txt": {Data: []byte("one")},
+}
+
+func TestRouterServeFiles(t *testing.T) {
+	r := New()
+	files := r.ServeFiles("/static/*filepath", testFS)
+
+	trw := testRequest(r, "GET", "/static/css/app.css", nil)
+	if trw.Body.String() != "body { color: red; }" {
+		t.Error("Expected", "body { color: red; }", "got", trw.Body.String())
+	}
+	if trw.Header().Get("Content-Type") != "text/css; charset=utf-8" {
+		t.Error("Expected", "text/css; charset=utf-8", "got", trw.Header().Get("Content-Type"))
+	}
+	etag := trw.Header().Get("Etag")
+	if etag == "" {
+		t.Fatal("Expected ETag header")
+	}
+	trw = testRequest(r, "GET", "/static/css/app.css", map[string]string{"If-None-Match": etag})
+	if trw.Code != http.StatusNotModified {
+		t.Error("Expected", http.StatusNotModified, "got", trw.Code)
+	}
+	trw = testRequest(r, "GET", "/static/docs/readme.txt", map[string]string{"Range": "bytes=2-4"})
+	if trw.Code != http.StatusPartialContent || trw.Body.String() != "234" {
+		t.Error("Expected", http.StatusPartialContent, "234", "got", trw.Code, trw.Body.String())
+	}
+
+	// precompressed sibling
+	trw = testRequest(r, "GET", "/static/js/app.js", map[string]string{"Accept-Encoding": "gzip"})
+	if trw.Body.String() != "gzipped" || trw.Header().Get("Content-Encoding") != "gzip" {
+		t.Error("Expected gzipped content, got", trw.Body.String())
+	}
+	if trw.Header().Get("Content-Type") != "text/javascript; charset=utf-8" {
+		t.Error("Expected", "text/javascript; charset=utf-8", "got", trw.Header().Get("Content-Type"))
+	}
+	if trw = testRequest(r, "GET", "/static/js/app.js", nil); trw.Body.String() != "console.log('plain')" {
+		t.Error("Expected plain content, got", trw.Body.String())
+	}
+
+	// index file and directories
+	if trw = testRequest(r, "GET", "/static/", nil); trw.Body.String() != "<h1>Home</h1>" {
+		t.Error("Expected", "<h1>Home</h1>", "got", trw.Body.String())
+	}
+	trw = testRequest(r, "GET", "/static/docs", nil)
+	if trw.Code != http.StatusMovedPermanently || trw.Header().Get("Location") != "docs/" {
+		t.Error("Expected redirect to docs/, got", trw.Code, trw.Header().Get("Location"))
+	}
+	if trw = testRequest(r, "GET", "/static/docs/", nil); trw.Code != http.StatusNotFound {
+		t.Error("Expected", http.StatusNotFound, "got", trw.Code)
+	}
+	files.Listing = ListingJSON
+	trw = testRequest(r, "GET", "/static/docs/", nil)
+	var list []FileInfo
+	if err := json.Unmarshal(trw.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Name != "guide" || !list[0].IsDir || list[1].Size != 10 {
+		t.Error("Unexpected listing", list)
+	}
+	files.Listing = ListingHTML
+	trw = testRequest(r, "GET", "/static/docs/", nil)
+	expected := "<!doctype html>\n<title>/static/docs/</title>\n<pre>\n" +
+		"<a href=\"guide/\">guide/</a>\n<a href=\"readme.txt\">readme.txt</a>\n</pre>\n"
+	if trw.Body.String() != expected {
+		t.Error("Expected", expected, "got", trw.Body.String())
+	}
+
+	// traversal and missing files
+	if trw = testRequest(r, "GET", "/static/../secret", nil); trw.Code != http.StatusBadRequest {
+		t.Error("Expected", http.StatusBadRequest, "got", trw.Code)
+	}
+	if trw = testRequest(r, "GET", "/static/missing.txt", nil); trw.Code != http.StatusNotFound {
+		t.Error("Expected", http.StatusNotFound, "got", trw.Code)
+	}
+	files.NotFound = func(c *Control) {
+		c.Code(http.StatusNotFound).Body("No such file")
+	}
+	if trw = testRequest(r, "GET", "/static/missing.txt", nil); trw.Body.String() != "No such file" {
+		t.Error("Expected", "No such file", "got", trw.Body.String())
+	}
+}
+
+func TestRouterServeFilesPath(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for path without catch-all parameter")
+		}
+	}()
+	New().ServeFiles("/static/", testFS)
+}
+
+func TestRouterServeFilesFormatSuffix(t *testing.T) {
+	r := New()
+	r.Renderers = BuiltinRenderers()
+	r.FormatSuffix = true
+	r.ServeFiles("/static/*filepath", fstest.MapFS{
+		"data.json": {Data: []byte(`{"a":1}`)},
+		"a.txt":     {Data: []byte("text")},
+	})
+	r.GET("/static/users/:id", func(c *Control) {
+		c.Body(map[string]string{"id": c.Param("id")})
+	})
+
+	if trw := testRequest(r, "GET", "/static/data.json", nil); trw.Code != http.StatusOK || trw.Body.String() != `{"a":1}` {
+		t.Error("Expected file data.json, got", trw.Code, trw.Body.String())
+	}
+	if trw := testRequest(r, "GET", "/static/a.txt", nil); trw.Code != http.StatusOK || trw.Body.String() != "text" {
+		t.Error("Expected file a.txt, got", trw.Code, trw.Body.String())
+	}
+	trw := testRequest(r, "GET", "/static/users/1.xml", nil)
+	if trw.Header().Get("Content-Type") != MIMEXML || !strings.Contains(trw.Body.String(), "<id>1</id>") {
+		t.Error("Expected XML of parameter route, got", trw.Header().Get("Content-Type"), trw.Body.String())
+	}
+}
+
+func TestRouterServeFilesSources(t *testing.T) {
+	r := New()
+	r.Sources = []Source{SourceQuery}
+	r.ServeFiles("/static/*filepath", testFS)
+
+	if trw := testRequest(r, "GET", "/static/css/app.css", nil); trw.Code != http.StatusOK || trw.Body.String() != "body { color: red; }" {
+		t.Error("Expected file app.css, got", trw.Code, trw.Header().Get("Location"))
+	}
+	trw := testRequest(r, "GET", "/static/css/app.css?filepath=js/app.js", nil)
+	if trw.Body.String() != "body { color: red; }" {
+		t.Error("Expected path from the route only, got", trw.Body.String())
+	}
+}
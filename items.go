@@ -0,0 +1,222 @@
+// Copyright 2015 Igor Dolzhikov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+)
+
+// Item describes a resource by fields of Google JSON style guide,
+// it is embedded into items of collections:
+//
+//	type User struct {
+//		router.Item
+//		Name string `json:"name"`
+//	}
+type Item struct {
+	Kind     string     `json:"kind,omitempty"`
+	ETag     string     `json:"etag,omitempty"`
+	Updated  *time.Time `json:"updated,omitempty"`
+	SelfLink string     `json:"selfLink,omitempty"`
+	EditLink string     `json:"editLink,omitempty"`
+}
+
+// Data is a data member of Header with fields of Google JSON style guide.
+// Items of collections are rendered as "items", properties of a single resource
+// are rendered next to the fields.
+type Data struct {
+	Kind             string      `json:"kind,omitempty"`
+	Fields           string      `json:"fields,omitempty"`
+	ETag             string      `json:"etag,omitempty"`
+	Updated          time.Time   `json:"updated,omitempty"`
+	CurrentItemCount int         `json:"currentItemCount"`
+	ItemsPerPage     int         `json:"itemsPerPage,omitempty"`
+	StartIndex       int         `json:"startIndex,omitempty"`
+	TotalItems       int         `json:"totalItems,omitempty"`
+	PageIndex        int         `json:"pageIndex,omitempty"`
+	TotalPages       int         `json:"totalPages,omitempty"`
+	SelfLink         string      `json:"selfLink,omitempty"`
+	EditLink         string      `json:"editLink,omitempty"`
+	NextLink         string      `json:"nextLink,omitempty"`
+	PreviousLink     string      `json:"previousLink,omitempty"`
+	Items            interface{} `json:"items"`
+
+	// hasTotal is set if the total is known, zero totals are rendered too
+	hasTotal bool
+}
+
+// Kind sets a type of the data of the response, e.g. "user"
+func (c *Control) Kind(kind string) *Control {
+	c.checkReleased()
+	c.useData = true
+	c.useMetaData = true
+	c.data.Kind = kind
+	return c
+}
+
+// Fields sets a list of fields of the partial response, e.g. "name,email"
+func (c *Control) Fields(fields string) *Control {
+	c.checkReleased()
+	c.useData = true
+	c.useMetaData = true
+	c.data.Fields = fields
+	return c
+}
+
+// SelfLink sets a link of the data of the response,
+// by default it is a link of the request
+func (c *Control) SelfLink(link string) *Control {
+	c.checkReleased()
+	c.useData = true
+	c.useMetaData = true
+	c.data.SelfLink = link
+	return c
+}
+
+// SelfRoute sets a link of the data of the response to the named route (see Router.URL)
+func (c *Control) SelfRoute(name string, params ...Param) *Control {
+	c.checkReleased()
+	if link, err := c.URL(name, params...); err == nil {
+		c.SelfLink(link)
+	}
+	return c
+}
+
+// EditLink sets a link which is used to update or delete the data of the response
+func (c *Control) EditLink(link string) *Control {
+	c.checkReleased()
+	c.useData = true
+	c.useMetaData = true
+	c.data.EditLink = link
+	return c
+}
+
+// Item returns the description of an item of a collection
+// with selfLink of the named route (see Router.URL)
+func (c *Control) Item(kind, name string, params ...Param) Item {
+	c.checkReleased()
+	item := Item{Kind: kind}
+	item.SelfLink, _ = c.URL(name, params...)
+	return item
+}
+
+// itemData returns the data member of the metadata with the items or the resource.
+// ETag and Last-Modified of the response are used as etag and updated fields.
+func (c *Control) itemData(items interface{}) Data {
+	data := c.data
+	data.Items = items
+	if data.ETag == "" {
+		data.ETag = c.etag
+	}
+	if data.Updated.IsZero() {
+		data.Updated = c.lastModified
+	}
+	if data.SelfLink == "" && c.Request != nil {
+		data.SelfLink = c.Request.URL.RequestURI()
+	}
+	if page := c.page; page != nil {
+		data.CurrentItemCount = itemCount(items)
+		if data.CurrentItemCount < 0 {
+			data.CurrentItemCount = 0
+		}
+		data.ItemsPerPage = page.Limit
+		data.StartIndex = page.Offset + 1
+		data.PageIndex = page.Index()
+		if page.Total >= 0 {
+			data.TotalItems = page.Total
+			data.TotalPages = page.Pages()
+			data.hasTotal = true
+		}
+	}
+	return data
+}
+
+// MarshalJSON renders the fields in order of Google JSON style guide,
+// currentItemCount and items are rendered for collections only.
+// Members of a single resource take precedence over the fields with the same keys.
+func (d Data) MarshalJSON() ([]byte, error) {
+	collection := d.Items == nil || itemCount(d.Items) >= 0
+	var resource, members []byte
+	var keys map[string]json.RawMessage
+	if !collection {
+		content, err := json.Marshal(d.Items)
+		if err != nil {
+			return nil, err
+		}
+		resource = bytes.TrimSpace(content)
+		if len(resource) >= 2 && resource[0] == '{' {
+			if err := json.Unmarshal(resource, &keys); err != nil {
+				return nil, err
+			}
+			members = bytes.TrimSpace(resource[1 : len(resource)-1])
+		}
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	add := func(key string, value interface{}) error {
+		if _, ok := keys[key]; ok {
+			return nil
+		}
+		content, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(`"` + key + `":`)
+		buf.Write(content)
+		return nil
+	}
+	fields := []struct {
+		key   string
+		value interface{}
+		ok    bool
+	}{
+		{"kind", d.Kind, d.Kind != ""},
+		{"fields", d.Fields, d.Fields != ""},
+		{"etag", d.ETag, d.ETag != ""},
+		{"updated", d.Updated.Format(time.RFC3339), !d.Updated.IsZero()},
+		{"currentItemCount", d.CurrentItemCount, collection},
+		{"itemsPerPage", d.ItemsPerPage, d.ItemsPerPage != 0},
+		{"startIndex", d.StartIndex, d.StartIndex != 0},
+		{"totalItems", d.TotalItems, d.TotalItems != 0 || d.hasTotal},
+		{"pageIndex", d.PageIndex, d.PageIndex != 0},
+		{"totalPages", d.TotalPages, d.TotalPages != 0 || d.hasTotal},
+		{"selfLink", d.SelfLink, d.SelfLink != ""},
+		{"editLink", d.EditLink, d.EditLink != ""},
+		{"nextLink", d.NextLink, d.NextLink != ""},
+		{"previousLink", d.PreviousLink, d.PreviousLink != ""},
+	}
+	for _, field := range fields {
+		if field.ok {
+			if err := add(field.key, field.value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if collection {
+		if err := add("items", d.Items); err != nil {
+			return nil, err
+		}
+		buf.WriteByte('}')
+		return buf.Bytes(), nil
+	}
+	if keys == nil {
+		// scalar data is not a resource
+		if err := add("items", json.RawMessage(resource)); err != nil {
+			return nil, err
+		}
+	} else if len(members) > 0 {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		buf.Write(members)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,97 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testItemUser struct {
+	Item
+	Name string `json:"name"`
+}
+
+func TestDataMarshalJSON(t *testing.T) {
+	updated := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		data     Data
+		expected string
+	}{
+		{Data{Kind: "user", Items: testItemUser{Name: "Bob"}}, `{"kind":"user","name":"Bob"}`},
+		{Data{Kind: "user", ETag: `"v1"`, Updated: updated, Items: map[string]int{}},
+			`{"kind":"user","etag":"\"v1\"","updated":"2020-01-02T03:04:05Z"}`},
+		{Data{Kind: "users", Items: []string{}}, `{"kind":"users","currentItemCount":0,"items":[]}`},
+		{Data{SelfLink: "/count", Items: 5}, `{"selfLink":"/count","items":5}`},
+		{Data{Kind: "user", SelfLink: "/users/1", Items: testItemUser{Item: Item{Kind: "admin"}, Name: "Bob"}},
+			`{"selfLink":"/users/1","kind":"admin","name":"Bob"}`},
+	}
+	for _, test := range tests {
+		if content, err := json.Marshal(test.data); err != nil || string(content) != test.expected {
+			t.Error("Expected", test.expected, "got", string(content), err)
+		}
+	}
+}
+
+func TestControlBodyItems(t *testing.T) {
+	modified := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	r := New()
+	r.ETag = StrongETag
+	r.GET("/users/:id", func(c *Control) {
+		c.Kind("user").ETag("v1").LastModified(modified).SelfRoute("user", Param{Key: "id", Value: c.Get(":id")}).
+			EditLink("/admin/users/" + c.Get(":id")).Body(testItemUser{Name: "Bob"})
+	}).Name("user")
+	r.GET("/users", func(c *Control) {
+		c.Paginate(10, 10)
+		users := []testItemUser{{Item: c.Item("user", "user", Param{Key: "id", Value: "1"}), Name: "Bob"}}
+		users[0].ETag = `"v1"`
+		c.Kind("userList").Fields("name").Total(1).Body(users)
+	})
+
+	trw := testRequest(r, "GET", "/users/1?full=true", nil)
+	expected := `{
+  "params": [
+    {
+      "key": ":id",
+      "value": "1"
+    }
+  ],
+  "data": {
+    "kind": "user",
+    "etag": "\"v1\"",
+    "updated": "2020-01-02T03:04:05Z",
+    "selfLink": "/users/1",
+    "editLink": "/admin/users/1",
+    "name": "Bob"
+  }
+}`
+	if body := strings.TrimSpace(trw.Body.String()); body != expected {
+		t.Error("Expected", expected, "got", body)
+	}
+	if trw.Header().Get("ETag") != `"v1"` {
+		t.Error("Expected", `"v1"`, "got", trw.Header().Get("ETag"))
+	}
+	if trw = testRequest(r, "GET", "/users/1", map[string]string{"If-None-Match": `"v1"`}); trw.Code != http.StatusNotModified {
+		t.Error("Expected", http.StatusNotModified, "got", trw.Code)
+	}
+
+	trw = testRequest(r, "GET", "/users?limit=5", nil)
+	var header struct {
+		Data struct {
+			Data
+			Items []testItemUser `json:"items"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(trw.Body.Bytes(), &header); err != nil {
+		t.Fatal(err, trw.Body.String())
+	}
+	data := header.Data
+	if data.Kind != "userList" || data.Fields != "name" || data.SelfLink != "/users?limit=5" ||
+		data.CurrentItemCount != 1 || data.TotalItems != 1 || len(data.Items) != 1 {
+		t.Error("Expected collection data, got", trw.Body.String())
+	}
+	if item := data.Items[0]; item.Kind != "user" || item.SelfLink != "/users/1" || item.ETag != `"v1"` || item.Name != "Bob" {
+		t.Error("Expected item metadata, got", item)
+	}
+}
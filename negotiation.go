@@ -0,0 +1,172 @@
+// Copyright 2015 Igor Dolzhikov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Content types of response formats
+const (
+	// MIMECSV - "Content-type" for CSV
+	MIMECSV = "text/csv"
+	// MIMEForm - "Content-type" for URL encoded form
+	MIMEForm = "application/x-www-form-urlencoded"
+)
+
+// suffixRenderer returns the path without a format suffix and the renderer of the suffix,
+// the renderer is nil if the path has no known suffix
+func suffixRenderer(path string, renderers []Renderer) (string, Renderer) {
+	for _, renderer := range renderers {
+		if sr, ok := renderer.(SuffixRenderer); ok {
+			suffix := sr.Suffix()
+			if suffix != "" && len(path) > len(suffix)+1 && strings.HasSuffix(path, suffix) {
+				return path[:len(path)-len(suffix)], renderer
+			}
+		}
+	}
+	return path, nil
+}
+
+// addVary adds the name of the request header to "Vary" header if it is not there yet
+func addVary(header http.Header, name string) {
+	if !headerHasToken(header, "Vary", name) {
+		header.Add("Vary", name)
+	}
+}
+
+// negotiate returns the renderer which is acceptable by the "Accept" header
+// with the highest quality, or nil if none of renderers are acceptable
+func negotiate(accept string, renderers []Renderer) Renderer {
+	if len(renderers) == 0 {
+		return nil
+	}
+	if strings.TrimSpace(accept) == "" {
+		return renderers[0]
+	}
+	ranges := parseAccept(accept)
+	var best Renderer
+	var bestQuality float64
+	for _, renderer := range renderers {
+		if q := acceptQuality(ranges, renderer.ContentType()); q > bestQuality {
+			best, bestQuality = renderer, q
+		}
+	}
+	return best
+}
+
+// acceptRange is a media range of the "Accept" header
+type acceptRange struct {
+	mediaType string
+	quality   float64
+}
+
+// parseAccept parses media ranges and their qualities
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+	for _, item := range strings.Split(accept, ",") {
+		parts := strings.Split(item, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(parts[0]))
+		if mediaType == "" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range parts[1:] {
+			if key, value, ok := strings.Cut(strings.TrimSpace(param), "="); ok && strings.TrimSpace(key) == "q" {
+				if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q >= 0 && q <= 1 {
+					quality = q
+				}
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType: mediaType, quality: quality})
+	}
+	return ranges
+}
+
+// acceptQuality returns a quality of the most specific media range which matches the content type
+func acceptQuality(ranges []acceptRange, contentType string) float64 {
+	if idx := strings.IndexByte(contentType, ';'); idx >= 0 {
+		contentType = strings.TrimSpace(contentType[:idx])
+	}
+	contentType = strings.ToLower(contentType)
+	mainType := contentType
+	if idx := strings.IndexByte(contentType, '/'); idx >= 0 {
+		mainType = contentType[:idx]
+	}
+	quality, specificity := 0.0, -1
+	for _, r := range ranges {
+		current := -1
+		switch r.mediaType {
+		case contentType:
+			current = 2
+		case mainType + "/*":
+			current = 1
+		case "*/*":
+			current = 0
+		}
+		if current > specificity {
+			quality, specificity = r.quality, current
+		}
+	}
+	return quality
+}
+
+// renderer returns the renderer of the response selected by URL suffix or "Accept" header,
+// JSON renderer follows the mode of CompactJSON
+func (c *Control) renderer() Renderer {
+	renderer := c.format
+	if renderer == nil {
+		renderer = c.negotiate()
+	}
+	if c.hasCompact && renderer != nil && renderer.ContentType() == MIMEJSON {
+		return JSONRenderer{Compact: c.compact}
+	}
+	return renderer
+}
+
+// negotiate returns the renderer selected by "Accept" header or nil if none is acceptable
+func (c *Control) negotiate() Renderer {
+	renderers := c.renderList()
+	if renderer := negotiate(c.Request.Header.Get("Accept"), renderers); renderer != nil {
+		return renderer
+	}
+	// JSON is the default format unless renderers are configured
+	if c.renderers == nil && (c.route == nil || c.route.renderers == nil) &&
+		(c.router == nil || c.router.Renderers == nil) {
+		return renderers[0]
+	}
+	return nil
+}
+
+// renderList returns renderers which are offered in content negotiation:
+// renderers of the Control, of the route or of the router
+func (c *Control) renderList() []Renderer {
+	switch {
+	case c.renderers != nil:
+		return c.renderers
+	case c.route != nil && c.route.renderers != nil:
+		return c.route.renderers
+	case c.router != nil && c.router.Renderers != nil:
+		return c.router.Renderers
+	}
+	return defaultRenderers
+}
+
+// notAcceptable is called when none of formats are acceptable by the client
+func (c *Control) notAcceptable() {
+	if c.router != nil && c.router.NotAcceptable != nil {
+		c.router.NotAcceptable(c)
+		return
+	}
+	renderers := c.renderList()
+	types := make([]string, len(renderers))
+	for idx, renderer := range renderers {
+		types[idx] = renderer.ContentType()
+	}
+	http.Error(c.Writer, http.StatusText(http.StatusNotAcceptable)+", available: "+strings.Join(types, ", "),
+		http.StatusNotAcceptable)
+}
@@ -0,0 +1,194 @@
+package router
+
+import (
+	"net/http"
+	"testing"
+)
+
+type testReportRow struct {
+	Name    string            `json:"name"`
+	Age     int               `json:"age"`
+	Address map[string]string `json:"address,omitempty"`
+}
+
+var testReport = []testReportRow{
+	{Name: "John", Age: 32, Address: map[string]string{"city": "Moscow"}},
+	{Name: "Bart \"B\"", Age: 10},
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := map[string]string{
+		"":                                       MIMEJSON,
+		"*/*":                                    MIMEJSON,
+		"application/xml":                        MIMEXML,
+		"text/csv;q=0.5, application/yaml;q=0.9": MIMEYAML,
+		"application/*;q=0.1, text/csv":          MIMECSV,
+		"application/json;q=0, */*":              MIMEXML,
+		"text/*":                                 MIMECSV,
+		"application/x-www-form-urlencoded":      MIMEForm,
+	}
+	for accept, expected := range tests {
+		if renderer := negotiate(accept, BuiltinRenderers()); renderer == nil || renderer.ContentType() != expected {
+			t.Error("Expected", expected, "for", accept, "got", renderer)
+		}
+	}
+	if renderer := negotiate("image/png, application/json;q=0", BuiltinRenderers()); renderer != nil {
+		t.Error("Expected no acceptable format, got", renderer.ContentType())
+	}
+}
+
+func TestControlBodyFormats(t *testing.T) {
+	r := New()
+	r.Renderers = BuiltinRenderers()
+	r.GET("/report", func(c *Control) {
+		c.Renderers(XMLRenderer{Compact: true}, CSVRenderer{}).Body(testReport)
+	})
+	r.GET("/users/:id", func(c *Control) {
+		c.Body(testReport[0])
+	})
+
+	trw := testRequest(r, "GET", "/report", map[string]string{"Accept": "text/csv"})
+	expected := "name,age,address.city\nJohn,32,Moscow\n\"Bart \"\"B\"\"\",10,\n"
+	if trw.Body.String() != expected || trw.Header().Get("Content-Type") != MIMECSV {
+		t.Error("Expected", expected, "got", trw.Body.String())
+	}
+	if trw.Header().Get("Vary") != "Accept" {
+		t.Error("Expected", "Accept", "got", trw.Header().Get("Vary"))
+	}
+
+	trw = testRequest(r, "GET", "/users/1", map[string]string{"Accept": "application/xml"})
+	expected = "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<response>\n  <name>John</name>\n  <age>32</age>\n" +
+		"  <address>\n    <city>Moscow</city>\n  </address>\n</response>\n"
+	if trw.Body.String() != expected {
+		t.Error("Expected", expected, "got", trw.Body.String())
+	}
+
+	trw = testRequest(r, "GET", "/report", map[string]string{"Accept": "application/xml"})
+	expected = "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<response><item><name>John</name><age>32</age>" +
+		"<address><city>Moscow</city></address></item><item><name>Bart &#34;B&#34;</name><age>10</age></item></response>"
+	if trw.Body.String() != expected {
+		t.Error("Expected", expected, "got", trw.Body.String())
+	}
+
+	trw = testRequest(r, "GET", "/users/1", map[string]string{"Accept": "application/yaml"})
+	expected = "name: John\nage: 32\naddress:\n  city: Moscow\n"
+	if trw.Body.String() != expected {
+		t.Error("Expected", expected, "got", trw.Body.String())
+	}
+
+	trw = testRequest(r, "GET", "/users/1", map[string]string{"Accept": MIMEForm})
+	if trw.Body.String() != "name=John&age=32&address.city=Moscow" {
+		t.Error("Expected", "name=John&age=32&address.city=Moscow", "got", trw.Body.String())
+	}
+
+	trw = testRequest(r, "GET", "/users/1", map[string]string{"Accept": "image/png"})
+	if trw.Code != http.StatusNotAcceptable {
+		t.Error("Expected", http.StatusNotAcceptable, "got", trw.Code)
+	}
+	r.NotAcceptable = func(c *Control) {
+		c.Code(http.StatusNotAcceptable).Body("Only JSON and XML")
+	}
+	if trw = testRequest(r, "GET", "/users/1", map[string]string{"Accept": "image/png"}); trw.Body.String() != "Only JSON and XML" {
+		t.Error("Expected", "Only JSON and XML", "got", trw.Body.String())
+	}
+}
+
+func TestControlBodyFormatSuffix(t *testing.T) {
+	r := New()
+	r.Renderers = BuiltinRenderers()
+	r.GET("/users/:id", func(c *Control) {
+		c.Body(map[string]string{"id": c.Param("id")})
+	})
+	trw := testRequest(r, "GET", "/users/1.xml", map[string]string{"Accept": "application/json"})
+	if trw.Header().Get("Content-Type") != MIMEJSON || trw.Code != http.StatusOK {
+		t.Error("Expected", MIMEJSON, "got", trw.Header().Get("Content-Type"))
+	}
+	r.FormatSuffix = true
+	trw = testRequest(r, "GET", "/users/1.xml", map[string]string{"Accept": "application/json"})
+	expected := "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<response>\n  <id>1</id>\n</response>\n"
+	if trw.Header().Get("Content-Type") != MIMEXML || trw.Body.String() != expected {
+		t.Error("Expected", expected, "got", trw.Body.String())
+	}
+}
+
+func TestMetaDataFormats(t *testing.T) {
+	r := New()
+	r.Renderers = BuiltinRenderers()
+	r.GET("/users/:id", func(c *Control) {
+		c.SetError(http.StatusNotFound, "User not found").Code(http.StatusNotFound).Body(nil)
+	})
+	trw := testRequest(r, "GET", "/users/1", map[string]string{"Accept": "application/xml"})
+	expected := "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<response>\n  <params>\n    <item>\n" +
+		"      <key>:id</key>\n      <value>1</value>\n    </item>\n  </params>\n  <error>\n" +
+		"    <code>404</code>\n    <message>User not found</message>\n  </error>\n</response>\n"
+	if trw.Code != http.StatusNotFound || trw.Body.String() != expected {
+		t.Error("Expected", expected, "got", trw.Body.String())
+	}
+	trw = testRequest(r, "GET", "/users/1", map[string]string{"Accept": "text/csv"})
+	expected = "params.0.key,params.0.value,error.code,error.message\n:id,1,404,User not found\n"
+	if trw.Body.String() != expected {
+		t.Error("Expected", expected, "got", trw.Body.String())
+	}
+}
+
+func TestControlBodyVary(t *testing.T) {
+	r := New()
+	r.Renderers = BuiltinRenderers()
+	r.FormatSuffix = true
+	r.GET("/users/:id", func(c *Control) {
+		c.Writer.Header().Add("Vary", "Accept")
+		c.Body(map[string]string{"id": c.Param("id")})
+	})
+	r.GET("/groups/:id", func(c *Control) {
+		c.Body(map[string]string{"id": c.Param("id")})
+	})
+	accept := map[string]string{"Accept": MIMEJSON}
+	if vary := testRequest(r, "GET", "/users/1", accept).Header()["Vary"]; len(vary) != 1 || vary[0] != "Accept" {
+		t.Error("Expected single Vary, got", vary)
+	}
+	if vary := testRequest(r, "GET", "/groups/1.xml", accept).Header()["Vary"]; len(vary) != 0 {
+		t.Error("Expected no Vary for suffix format, got", vary)
+	}
+}
+
+func TestControlBodyDefaultFormat(t *testing.T) {
+	r := New()
+	r.GET("/users/:id", func(c *Control) {
+		c.Body(testReport[0])
+	})
+	for _, accept := range []string{"text/html", "application/xml;q=0.9,*/*;q=0.8", "image/png"} {
+		trw := testRequest(r, "GET", "/users/1", map[string]string{"Accept": accept})
+		if trw.Code != http.StatusOK || trw.Header().Get("Content-Type") != MIMEJSON {
+			t.Error("Expected", MIMEJSON, "for", accept, "got", trw.Code, trw.Header().Get("Content-Type"))
+		} else if vary := trw.Header()["Vary"]; len(vary) != 0 {
+			t.Error("Expected no Vary for the single format, got", vary)
+		}
+	}
+	r.Renderers = BuiltinRenderers()
+	trw := testRequest(r, "GET", "/users/1", map[string]string{"Accept": "application/xml;q=0.9,*/*;q=0.8"})
+	if trw.Header().Get("Content-Type") != MIMEXML || trw.Header().Get("Vary") != "Accept" {
+		t.Error("Expected", MIMEXML, "with Vary, got", trw.Header().Get("Content-Type"), trw.Header().Get("Vary"))
+	}
+	if trw = testRequest(r, "GET", "/users/1", map[string]string{"Accept": "text/html"}); trw.Code != http.StatusNotAcceptable {
+		t.Error("Expected", http.StatusNotAcceptable, "got", trw.Code)
+	}
+}
+
+func TestControlBodyCompactDefaultFormat(t *testing.T) {
+	r := New()
+	r.GET("/users/:id", func(c *Control) {
+		c.CompactJSON(true).Body(map[string]string{"id": c.Param("id")})
+	})
+	trw := testRequest(r, "GET", "/users/1", map[string]string{"Accept": "text/html"})
+	if trw.Code != http.StatusOK || trw.Header().Get("Content-Type") != MIMEJSON || trw.Body.String() != `{"id":"1"}` {
+		t.Error("Expected", `{"id":"1"}`, "got", trw.Code, trw.Body.String())
+	}
+	r.Renderers = BuiltinRenderers()
+	r.FormatSuffix = true
+	if trw = testRequest(r, "GET", "/users/1.json", map[string]string{"Accept": MIMEXML}); trw.Body.String() != `{"id":"1"}` {
+		t.Error("Expected", `{"id":"1"}`, "got", trw.Body.String())
+	}
+	if trw = testRequest(r, "GET", "/users/1", map[string]string{"Accept": MIMEXML}); trw.Header().Get("Content-Type") != MIMEXML {
+		t.Error("Expected", MIMEXML, "got", trw.Header().Get("Content-Type"))
+	}
+}
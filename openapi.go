@@ -0,0 +1,391 @@
+// Copyright 2015 Igor Dolzhikov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenAPIVersion is a version of OpenAPI specification of generated documents
+const OpenAPIVersion = "3.0.3"
+
+// MIMEYAML - "Content-type" for YAML
+const MIMEYAML = "application/yaml"
+
+// OpenAPI is a document which describes registered routes
+type OpenAPI struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components *OpenAPIComponents                      `json:"components,omitempty"`
+}
+
+// OpenAPIInfo contains general information about API
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// OpenAPIComponents contains reusable schemas
+type OpenAPIComponents struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// OpenAPIOperation describes a single API operation on a path
+type OpenAPIOperation struct {
+	Tags        []string                    `json:"tags,omitempty"`
+	Summary     string                      `json:"summary,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	Parameters  []OpenAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+}
+
+// OpenAPIParameter describes a single operation parameter
+type OpenAPIParameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// OpenAPIRequestBody describes a request body
+type OpenAPIRequestBody struct {
+	Required bool                         `json:"required"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse describes a single response of an operation
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType provides schema of a media type
+type OpenAPIMediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is a subset of JSON Schema used by OpenAPI
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+// Schema name of the standard error envelope
+const errorResponseSchema = "ErrorResponse"
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	durationType      = reflect.TypeOf(time.Duration(0))
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Summary adds a short summary of the route
+func (rt *Route) Summary(summary string) *Route {
+	rt.summary = summary
+	return rt
+}
+
+// Description adds a verbose explanation of the route
+func (rt *Route) Description(description string) *Route {
+	rt.description = description
+	return rt
+}
+
+// Tags adds tags for logical grouping of routes
+func (rt *Route) Tags(tags ...string) *Route {
+	rt.tags = append(rt.tags, tags...)
+	return rt
+}
+
+// Request defines a type of data expected in the request body
+func (rt *Route) Request(data interface{}) *Route {
+	rt.request = data
+	return rt
+}
+
+// Response defines a type of data returned with the status code
+func (rt *Route) Response(code int, data interface{}) *Route {
+	if rt.responses == nil {
+		rt.responses = make(map[int]interface{})
+	}
+	rt.responses[code] = data
+	return rt
+}
+
+// Error defines status codes returned with standard error envelope (ErrorHeader)
+func (rt *Route) Error(codes ...int) *Route {
+	rt.errors = append(rt.errors, codes...)
+	return rt
+}
+
+// Undocumented excludes the route from OpenAPI document
+func (rt *Route) Undocumented() *Route {
+	rt.undocumented = true
+	return rt
+}
+
+// OpenAPI generates OpenAPI document from the registered routes
+func (r *Router) OpenAPI(info OpenAPIInfo) *OpenAPI {
+	doc := &OpenAPI{
+		OpenAPI: OpenAPIVersion,
+		Info:    info,
+		Paths:   make(map[string]map[string]*OpenAPIOperation),
+	}
+	s := &schemas{components: make(map[string]*Schema), types: make(map[reflect.Type]string)}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, route := range r.routes {
+		if route.undocumented || route.disabled || trim(route.Path, " ") == asterisk {
+			continue
+		}
+		path, params := openAPIPath(route.Path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*OpenAPIOperation)
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = s.operation(route, params)
+	}
+	if len(s.components) > 0 {
+		doc.Components = &OpenAPIComponents{Schemas: s.components}
+	}
+
+	return doc
+}
+
+// JSON returns OpenAPI document in JSON format
+func (doc *OpenAPI) JSON() ([]byte, error) {
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// YAML returns OpenAPI document in YAML format
+func (doc *OpenAPI) YAML() ([]byte, error) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return jsonToYAML(data)
+}
+
+// ServeOpenAPI registers GET handler which serves OpenAPI document on the path.
+// The document is in YAML format if the path has ".yaml" or ".yml" extension.
+func (r *Router) ServeOpenAPI(path string, info OpenAPIInfo) *Route {
+	yaml := strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml")
+	return r.GET(path, func(c *Control) {
+		var content []byte
+		var err error
+		if yaml {
+			c.ContentType = MIMEYAML
+			content, err = r.OpenAPI(info).YAML()
+		} else {
+			c.ContentType = MIMEJSON
+			content, err = r.OpenAPI(info).JSON()
+		}
+		if err != nil {
+			c.Code(http.StatusInternalServerError).Body(http.StatusText(http.StatusInternalServerError))
+			return
+		}
+		c.Body(string(content))
+	}).Undocumented()
+}
+
+// openAPIPath converts path with ":param" patterns into path with "{param}" templates
+func openAPIPath(path string) (string, []string) {
+	parts, _ := split(path)
+	var params []string
+	for idx, value := range parts {
+		if name := value[1:]; value[0] == ':' || value[0] == '*' {
+			if name == "" {
+				name = "wildcard"
+			}
+			params = append(params, name)
+			parts[idx] = "{" + name + "}"
+		}
+	}
+
+	return "/" + join(parts), params
+}
+
+// schemas collects reusable schemas of named types
+type schemas struct {
+	components map[string]*Schema
+	types      map[reflect.Type]string
+}
+
+func (s *schemas) operation(route *Route, params []string) *OpenAPIOperation {
+	op := &OpenAPIOperation{
+		Tags:        route.tags,
+		Summary:     route.summary,
+		Description: route.description,
+		Responses:   make(map[string]*OpenAPIResponse),
+	}
+	for _, name := range params {
+		op.Parameters = append(op.Parameters, OpenAPIParameter{
+			Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"},
+		})
+	}
+	if route.request != nil {
+		op.RequestBody = &OpenAPIRequestBody{
+			Required: true,
+			Content:  map[string]*OpenAPIMediaType{MIMEJSON: {Schema: s.schema(reflect.TypeOf(route.request))}},
+		}
+	}
+	for code, data := range route.responses {
+		response := &OpenAPIResponse{Description: http.StatusText(code)}
+		if data != nil {
+			response.Content = map[string]*OpenAPIMediaType{MIMEJSON: {Schema: s.schema(reflect.TypeOf(data))}}
+		}
+		op.Responses[strconv.Itoa(code)] = response
+	}
+	if len(route.errors) > 0 {
+		ref := s.errorResponse()
+		for _, code := range route.errors {
+			op.Responses[strconv.Itoa(code)] = &OpenAPIResponse{
+				Description: http.StatusText(code),
+				Content:     map[string]*OpenAPIMediaType{MIMEJSON: {Schema: ref}},
+			}
+		}
+	}
+	if len(op.Responses) == 0 {
+		op.Responses["default"] = &OpenAPIResponse{Description: "Default response"}
+	}
+
+	return op
+}
+
+// errorResponse registers schema of the standard error envelope
+func (s *schemas) errorResponse() *Schema {
+	if _, ok := s.components[errorResponseSchema]; !ok {
+		s.components[errorResponseSchema] = &Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"error": s.schema(reflect.TypeOf(ErrorHeader{})),
+			},
+		}
+	}
+	return &Schema{Ref: "#/components/schemas/" + errorResponseSchema}
+}
+
+// schema reflects Go type into schema, named structures are placed into components
+func (s *schemas) schema(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	if t.Kind() == reflect.Ptr {
+		schema := s.schema(t.Elem())
+		if schema.Ref == "" {
+			schema.Nullable = true
+		}
+		return schema
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == durationType:
+		return &Schema{Type: "integer", Format: "int64"}
+	case t == rawMessageType:
+		return &Schema{}
+	case t.Kind() != reflect.Struct && t.Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		name, ok := s.types[t]
+		if !ok {
+			name = t.Name()
+			for idx := 2; s.components[name] != nil; idx++ {
+				name = t.Name() + strconv.Itoa(idx)
+			}
+			s.types[t] = name
+			// reserve the name before reflection of fields for recursive types
+			s.components[name] = &Schema{}
+			*s.components[name] = *s.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+
+	return &Schema{}
+}
+
+// object reflects fields of the structure using JSON tags
+func (s *schemas) object(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	s.fields(t, schema)
+	return schema
+}
+
+func (s *schemas) fields(t reflect.Type, schema *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options := tag, ""
+		if idx := strings.Index(tag, ","); idx >= 0 {
+			name, options = tag[:idx], tag[idx:]
+		}
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				s.fields(ft, schema)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		property := s.schema(field.Type)
+		if strings.Contains(options, ",string") {
+			property = &Schema{Type: "string"}
+		}
+		schema.Properties[name] = property
+		if !strings.Contains(options, ",omitempty") && field.Type.Kind() != reflect.Ptr {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
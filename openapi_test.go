@@ -0,0 +1,133 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testUser struct {
+	ID      int64      `json:"id"`
+	Name    string     `json:"name"`
+	Email   string     `json:"email,omitempty"`
+	Created time.Time  `json:"created"`
+	Friends []testUser `json:"friends,omitempty"`
+	Manager *testUser  `json:"manager,omitempty"`
+	secret  string
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	r := New()
+	r.GET("/users/:id", func(c *Control) {}).
+		Summary("Get user").Tags("users").Response(http.StatusOK, testUser{}).Error(http.StatusNotFound)
+	r.POST("/users", func(c *Control) {}).Request(testUser{}).Response(http.StatusCreated, nil)
+	r.GET("/files/*", func(c *Control) {})
+	r.ServeOpenAPI("/openapi.json", OpenAPIInfo{Title: "Test", Version: "1.0"})
+
+	doc := r.OpenAPI(OpenAPIInfo{Title: "Test", Version: "1.0"})
+	if doc.OpenAPI != OpenAPIVersion {
+		t.Error("Expected", OpenAPIVersion, "got", doc.OpenAPI)
+	}
+	if len(doc.Paths) != 3 {
+		t.Error("Expected paths", 3, "got", len(doc.Paths))
+	}
+	op := doc.Paths["/users/{id}"]["get"]
+	if op == nil {
+		t.Fatal("Operation not found: GET /users/{id}")
+	}
+	if op.Summary != "Get user" || len(op.Tags) != 1 || op.Tags[0] != "users" {
+		t.Error("Expected summary and tags, got", op.Summary, op.Tags)
+	}
+	if len(op.Parameters) != 1 || op.Parameters[0].Name != "id" || op.Parameters[0].In != "path" {
+		t.Error("Expected path parameter id, got", op.Parameters)
+	}
+	if ref := op.Responses["200"].Content[MIMEJSON].Schema.Ref; ref != "#/components/schemas/testUser" {
+		t.Error("Expected", "#/components/schemas/testUser", "got", ref)
+	}
+	if ref := op.Responses["404"].Content[MIMEJSON].Schema.Ref; ref != "#/components/schemas/ErrorResponse" {
+		t.Error("Expected", "#/components/schemas/ErrorResponse", "got", ref)
+	}
+	if doc.Paths["/users"]["post"].RequestBody == nil {
+		t.Error("Expected request body for POST /users")
+	}
+	if op := doc.Paths["/files/{wildcard}"]["get"]; op == nil || op.Responses["default"] == nil {
+		t.Error("Expected default response for GET /files/{wildcard}")
+	}
+	if doc.Components == nil {
+		t.Fatal("Components not found")
+	}
+	user := doc.Components.Schemas["testUser"]
+	if user == nil {
+		t.Fatal("Schema not found: testUser")
+	}
+	if user.Properties["created"].Format != "date-time" {
+		t.Error("Expected", "date-time", "got", user.Properties["created"].Format)
+	}
+	if user.Properties["friends"].Items.Ref != "#/components/schemas/testUser" {
+		t.Error("Expected reference to testUser, got", user.Properties["friends"].Items.Ref)
+	}
+	if _, ok := user.Properties["secret"]; ok {
+		t.Error("Unexpected unexported property: secret")
+	}
+	if strings.Join(user.Required, ",") != "id,name,created" {
+		t.Error("Expected", "id,name,created", "got", user.Required)
+	}
+	if doc.Components.Schemas["ErrorHeader"] == nil {
+		t.Error("Schema not found: ErrorHeader")
+	}
+}
+
+func TestOpenAPIServe(t *testing.T) {
+	r := New()
+	r.GET("/hello/:name", func(c *Control) {}).Summary("Say hello")
+	r.ServeOpenAPI("/openapi.json", OpenAPIInfo{Title: "Test", Version: "1.0"})
+	r.ServeOpenAPI("/openapi.yaml", OpenAPIInfo{Title: "Test", Version: "1.0"})
+
+	req, err := http.NewRequest("GET", "/openapi.json", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	trw := httptest.NewRecorder()
+	r.ServeHTTP(trw, req)
+	var doc OpenAPI
+	if err := json.Unmarshal(trw.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Paths) != 1 || doc.Paths["/hello/{name}"] == nil {
+		t.Error("Expected only path /hello/{name}, got", doc.Paths)
+	}
+
+	req, err = http.NewRequest("GET", "/openapi.yaml", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	trw = httptest.NewRecorder()
+	r.ServeHTTP(trw, req)
+	if trw.Header().Get("Content-type") != MIMEYAML {
+		t.Error("Expected", MIMEYAML, "got", trw.Header().Get("Content-type"))
+	}
+	expected := `openapi: "3.0.3"
+info:
+  title: Test
+  version: "1.0"
+paths:
+  /hello/{name}:
+    get:
+      summary: Say hello
+      parameters:
+        - name: name
+          in: path
+          required: true
+          schema:
+            type: string
+      responses:
+        default:
+          description: Default response
+`
+	if trw.Body.String() != expected {
+		t.Error("Expected", expected, "got", trw.Body.String())
+	}
+}
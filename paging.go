@@ -0,0 +1,144 @@
+// Copyright 2015 Igor Dolzhikov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Query parameters of pagination
+const (
+	// PageParam is a one-based index of the page
+	PageParam = "page"
+	// OffsetParam is a zero-based index of the first item, it is used instead of the page
+	OffsetParam = "offset"
+	// LimitParam is a number of items per page
+	LimitParam = "limit"
+)
+
+// Page is a part of a collection requested by query parameters
+type Page struct {
+	// Limit is a number of items per page
+	Limit int
+	// Offset is a zero-based index of the first item of the page
+	Offset int
+	// Total is a number of items in the collection, negative if it is unknown
+	Total int
+
+	// byOffset is set if the request uses offsets instead of pages,
+	// then links of other pages use offsets too
+	byOffset bool
+}
+
+// Index returns a one-based index of the page
+func (p Page) Index() int {
+	return p.Offset/p.Limit + 1
+}
+
+// Pages returns a number of pages, negative if the total is unknown
+func (p Page) Pages() int {
+	if p.Total < 0 {
+		return -1
+	}
+	return (p.Total + p.Limit - 1) / p.Limit
+}
+
+// Paginate returns the page requested by "page" or "offset" and "limit" query parameters.
+// Invalid values are replaced by defaults and the limit is bounded by maxLimit.
+// Body of the Control writes "Link" header with first, prev, next and last pages
+// and paging fields in data of the metadata (see Data).
+func (c *Control) Paginate(defaultLimit, maxLimit int) Page {
+	c.checkReleased()
+	query := c.Query()
+	page := Page{Limit: defaultLimit, Total: -1}
+	if limit, err := strconv.Atoi(query.Get(LimitParam)); err == nil && limit > 0 {
+		page.Limit = limit
+	}
+	if maxLimit > 0 && page.Limit > maxLimit {
+		page.Limit = maxLimit
+	}
+	if page.Limit <= 0 {
+		page.Limit = 1
+	}
+	// offsets which overflow links of the next page are replaced by the first page
+	maxOffset := math.MaxInt - page.Limit
+	if offset, err := strconv.Atoi(query.Get(OffsetParam)); err == nil && query.Get(PageParam) == "" {
+		page.byOffset = true
+		if offset > 0 && offset <= maxOffset {
+			page.Offset = offset
+		}
+	} else if index, err := strconv.Atoi(query.Get(PageParam)); err == nil && index > 1 && index-1 <= maxOffset/page.Limit {
+		page.Offset = (index - 1) * page.Limit
+	}
+	c.page = &page
+	return page
+}
+
+// Total sets a number of items in the collection of the page
+func (c *Control) Total(total int) *Control {
+	c.checkReleased()
+	if c.page != nil {
+		c.page.Total = total
+	}
+	return c
+}
+
+// pageLinks writes "Link" header and returns links of the previous and the next pages,
+// count is a number of items on the page, negative if it is unknown
+func (c *Control) pageLinks(count int) (previous, next string) {
+	page := c.page
+	var links []string
+	add := func(rel string, offset int) string {
+		link := c.pageURL(offset)
+		links = append(links, "<"+link+`>; rel="`+rel+`"`)
+		return link
+	}
+	if page.Offset > 0 {
+		add("first", 0)
+		offset := page.Offset - page.Limit
+		if offset < 0 {
+			offset = 0
+		}
+		previous = add("prev", offset)
+	}
+	if page.Total >= 0 {
+		if page.Offset+page.Limit < page.Total {
+			next = add("next", page.Offset+page.Limit)
+			add("last", page.Offset+(page.Total-page.Offset-1)/page.Limit*page.Limit)
+		}
+	} else if count >= page.Limit {
+		next = add("next", page.Offset+page.Limit)
+	}
+	if len(links) > 0 {
+		c.Writer.Header().Set("Link", strings.Join(links, ", "))
+	}
+	return previous, next
+}
+
+// pageURL returns URL of the request with paging parameters of the page at the offset
+func (c *Control) pageURL(offset int) string {
+	query := c.Request.URL.Query()
+	query.Set(LimitParam, strconv.Itoa(c.page.Limit))
+	if c.page.byOffset {
+		query.Set(OffsetParam, strconv.Itoa(offset))
+	} else {
+		query.Del(OffsetParam)
+		query.Set(PageParam, strconv.Itoa(offset/c.page.Limit+1))
+	}
+	return c.Request.URL.Path + "?" + query.Encode()
+}
+
+// itemCount returns a number of items of the slice or array, otherwise -1
+func itemCount(items interface{}) int {
+	value := reflect.ValueOf(items)
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		return value.Len()
+	}
+	return -1
+}
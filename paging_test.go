@@ -0,0 +1,107 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestControlPaginate(t *testing.T) {
+	tests := []struct {
+		query         string
+		limit, offset int
+	}{
+		{"", 10, 0},
+		{"?page=3", 10, 20},
+		{"?page=2&limit=5", 5, 5},
+		{"?offset=7&limit=500", 100, 7},
+		{"?page=-1&limit=-5", 10, 0},
+		{"?offset=abc", 10, 0},
+		{"?page=9223372036854775807&limit=50", 50, 0},
+		{"?page=184467440737095517&limit=50", 50, 0},
+		{"?offset=9223372036854775807", 10, 0},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest("GET", "/items"+test.query, nil)
+		c := &Control{Request: req}
+		if page := c.Paginate(10, 100); page.Limit != test.limit || page.Offset != test.offset || page.Total != -1 {
+			t.Error("Expected", test.limit, test.offset, "for", test.query, "got", page.Limit, page.Offset)
+		}
+	}
+}
+
+func TestControlBodyPaging(t *testing.T) {
+	items := make([]int, 45)
+	r := New()
+	r.GET("/items", func(c *Control) {
+		page := c.Paginate(10, 20)
+		end := page.Offset + page.Limit
+		if end > len(items) {
+			end = len(items)
+		}
+		c.UseMetaData().Total(len(items)).Body(items[page.Offset:end])
+	})
+	r.GET("/stream", func(c *Control) {
+		page := c.Paginate(10, 20)
+		c.Body(items[page.Offset : page.Offset+page.Limit])
+	})
+
+	trw := testRequest(r, "GET", "/items?page=2&sort=name", nil)
+	expected := `</items?limit=10&page=1&sort=name>; rel="first", </items?limit=10&page=1&sort=name>; rel="prev", ` +
+		`</items?limit=10&page=3&sort=name>; rel="next", </items?limit=10&page=5&sort=name>; rel="last"`
+	if link := trw.Header().Get("Link"); link != expected {
+		t.Error("Expected", expected, "got", link)
+	}
+	var header struct {
+		Data Data `json:"data"`
+	}
+	if err := json.Unmarshal(trw.Body.Bytes(), &header); err != nil {
+		t.Fatal(err)
+	}
+	data := header.Data
+	if data.CurrentItemCount != 10 || data.ItemsPerPage != 10 || data.StartIndex != 11 || data.TotalItems != 45 ||
+		data.PageIndex != 2 || data.TotalPages != 5 || data.NextLink != "/items?limit=10&page=3&sort=name" ||
+		data.PreviousLink != "/items?limit=10&page=1&sort=name" {
+		t.Error("Expected paging fields, got", data)
+	}
+
+	trw = testRequest(r, "GET", "/items?offset=37&limit=5", nil)
+	expected = `</items?limit=5&offset=0>; rel="first", </items?limit=5&offset=32>; rel="prev", ` +
+		`</items?limit=5&offset=42>; rel="next", </items?limit=5&offset=42>; rel="last"`
+	if link := trw.Header().Get("Link"); link != expected {
+		t.Error("Expected", expected, "got", link)
+	}
+	if trw = testRequest(r, "GET", "/items?page=5", nil); trw.Header().Get("Link") !=
+		`</items?limit=10&page=1>; rel="first", </items?limit=10&page=4>; rel="prev"` {
+		t.Error("Expected links of the last page, got", trw.Header().Get("Link"))
+	}
+
+	// total is unknown, metadata is not used
+	trw = testRequest(r, "GET", "/stream", nil)
+	if link := trw.Header().Get("Link"); link != `</stream?limit=10&page=2>; rel="next"` {
+		t.Error("Expected next link, got", link)
+	}
+	if body := trw.Body.String(); len(body) == 0 || body[0] != '[' {
+		t.Error("Expected items without metadata, got", trw.Body.String())
+	}
+}
+
+func TestControlBodyPagingEmpty(t *testing.T) {
+	r := New()
+	r.GET("/items", func(c *Control) {
+		c.Paginate(10, 100)
+		c.Total(0).UseMetaData().CompactJSON(true).Body([]int{})
+	})
+	r.GET("/unknown", func(c *Control) {
+		c.Paginate(10, 100)
+		c.Total(-1).UseMetaData().CompactJSON(true).Body([]int{})
+	})
+	body := testRequest(r, "GET", "/items", nil).Body.String()
+	if !strings.Contains(body, `"totalItems":0`) || !strings.Contains(body, `"totalPages":0`) {
+		t.Error("Expected known zero total, got", body)
+	}
+	if body = testRequest(r, "GET", "/unknown", nil).Body.String(); strings.Contains(body, "total") {
+		t.Error("Expected unknown total, got", body)
+	}
+}
@@ -6,6 +6,7 @@ package router
 
 import (
 	"sort"
+	"strings"
 )
 
 const (
@@ -15,14 +16,15 @@ const (
 
 type parser struct {
 	fields   map[uint8]records
-	static   map[string]Handle
+	static   map[string]*record
 	wildcard records
 }
 
 type record struct {
-	key    uint16
-	handle Handle
-	parts  []string
+	key     uint16
+	route   *Route
+	parts   []string
+	pattern string
 }
 
 type records []*record
@@ -31,17 +33,35 @@ func (n records) Len() int           { return len(n) }
 func (n records) Swap(i, j int)      { n[i], n[j] = n[j], n[i] }
 func (n records) Less(i, j int) bool { return n[i].key < n[j].key }
 
+// catchAll checks whether the record matches the rest of the path by a wildcard
+func (rec *record) catchAll() bool {
+	if rec.pattern == asterisk {
+		return true
+	}
+	for _, part := range rec.parts {
+		if strings.HasPrefix(part, asterisk) {
+			return true
+		}
+	}
+	return false
+}
+
 func newParser() *parser {
 	return &parser{
 		fields:   make(map[uint8]records),
-		static:   make(map[string]Handle),
+		static:   make(map[string]*record),
 		wildcard: records{},
 	}
 }
 
 func (p *parser) register(path string, handle Handle) bool {
+	return p.add(&Route{Path: path, handle: handle})
+}
+
+func (p *parser) add(route *Route) bool {
+	path := route.Path
 	if trim(path, " ") == asterisk {
-		p.static[asterisk] = handle
+		p.static[asterisk] = &record{route: route, pattern: asterisk}
 
 		return true
 	}
@@ -50,19 +70,20 @@ func (p *parser) register(path string, handle Handle) bool {
 		for _, value := range parts {
 			if len(value) >= 1 && value[0:1] == ":" {
 				dynamic++
-			} else if len(value) == 1 && value == "*" {
+			} else if len(value) >= 1 && value[0:1] == asterisk {
 				wildcard++
 			} else {
 				static++
 			}
 		}
+		rec := &record{key: dynamic<<8 + static, route: route, parts: parts, pattern: "/" + join(parts)}
 		if wildcard > 0 {
-			p.wildcard = append(p.wildcard, &record{key: dynamic<<8 + static, handle: handle, parts: parts})
+			p.wildcard = append(p.wildcard, rec)
 		} else if dynamic == 0 {
-			p.static["/"+join(parts)] = handle
+			p.static[rec.pattern] = rec
 		} else {
 			level := uint8(len(parts))
-			p.fields[level] = append(p.fields[level], &record{key: dynamic<<8 + static, handle: handle, parts: parts})
+			p.fields[level] = append(p.fields[level], rec)
 			sort.Sort(records(p.fields[level]))
 		}
 		return true
@@ -72,51 +93,88 @@ func (p *parser) register(path string, handle Handle) bool {
 }
 
 func (p *parser) get(path string) (handle Handle, result []Param, ok bool) {
-	if handle, ok := p.static[asterisk]; ok {
-		return handle, nil, true
+	if rec, result, ok := p.match(path); ok {
+		return rec.route.handle, result, true
+	}
+
+	return nil, nil, false
+}
+
+func (p *parser) match(path string) (*record, []Param, bool) {
+	rec, _, params, ok := p.lookup(path, nil, nil)
+	return rec, params, ok
+}
+
+// lookup finds a record for the path, parts and params are used as buffers
+// to avoid allocations and returned with their new content
+func (p *parser) lookup(path string, parts []string, params []Param) (*record, []string, []Param, bool) {
+	if rec, ok := p.static[asterisk]; ok {
+		return rec, parts, params[:0], true
 	}
-	if handle, ok := p.static[path]; ok {
-		return handle, nil, true
+	if rec, ok := p.static[path]; ok {
+		return rec, parts, params[:0], true
 	}
-	if parts, ok := split(path); ok {
-		if handle, ok := p.static["/"+join(parts)]; ok {
-			return handle, nil, true
+	parts, ok := splitInto(path, parts)
+	if ok {
+		// normalized path differs from the path already checked above
+		if !isJoined(path, parts) {
+			if rec, ok := p.static["/"+join(parts)]; ok {
+				return rec, parts, params[:0], true
+			}
 		}
 		if data := p.fields[uint8(len(parts))]; data != nil {
-			if handle, result, ok := parseParams(data, parts); ok {
-				return handle, result, ok
+			if rec, result, ok := parseParams(data, parts, params); ok {
+				return rec, parts, result, ok
 			}
 		}
 		// try to match wildcard route
-		if handle, result, ok := parseParams(p.wildcard, parts); ok {
-			return handle, result, ok
+		if rec, result, ok := parseParams(p.wildcard, parts, params); ok {
+			return rec, parts, result, ok
 		}
 	}
 
-	return nil, nil, false
+	return nil, parts, params, false
 }
 
 func split(path string) ([]string, bool) {
-	sdata := explode(trim(path, "/"))
-	if len(sdata) == 0 {
-		return sdata, true
-	}
-	var result []string
-	ind := 0
-	if len(sdata) < maxLevel {
-		result = make([]string, len(sdata))
-		for _, value := range sdata {
-			if v := trim(value, " "); v == "" {
-				continue
-			} else {
-				result[ind] = v
-				ind++
+	return splitInto(path, nil)
+}
+
+// splitInto splits the path into non-empty parts which are appended to dst[:0]
+func splitInto(path string, dst []string) ([]string, bool) {
+	path = trim(path, "/")
+	dst = dst[:0]
+	if len(path) == 0 {
+		return dst, true
+	}
+	if strings.Count(path, "/")+1 >= maxLevel {
+		return nil, false
+	}
+	start := 0
+	for i := 0; i <= len(path); i++ {
+		if i == len(path) || path[i] == '/' {
+			if v := trim(path[start:i], " "); v != "" {
+				dst = append(dst, v)
 			}
+			start = i + 1
 		}
-		return result[0:ind], true
 	}
+	return dst, true
+}
 
-	return nil, false
+// isJoined checks if the path equals to "/" + join(parts) without allocation
+func isJoined(path string, parts []string) bool {
+	if len(parts) == 0 {
+		return path == "/"
+	}
+	pos := 0
+	for _, part := range parts {
+		if pos >= len(path) || path[pos] != '/' || !strings.HasPrefix(path[pos+1:], part) {
+			return false
+		}
+		pos += len(part) + 1
+	}
+	return pos == len(path)
 }
 
 func trim(str, sep string) string {
@@ -159,39 +217,20 @@ func join(parts []string) string {
 	return string(b)
 }
 
-func explode(s string) []string {
-	if len(s) == 0 {
-		return []string{}
-	}
-	n := 1
-	sep := "/"
-	c := sep[0]
-	for i := 0; i < len(s); i++ {
-		if s[i] == c {
-			n++
-		}
-	}
-	start := 0
-	a := make([]string, n)
-	na := 0
-	for i := 0; i+1 <= len(s) && na+1 < n; i++ {
-		if s[i] == c {
-			a[na] = s[start:i]
-			na++
-			start = i + 1
-		}
-	}
-	a[na] = s[start:]
-	return a[0 : na+1]
-}
-
-func parseParams(data records, parts []string) (handle Handle, result []Param, ok bool) {
+func parseParams(data records, parts []string, buf []Param) (rec *record, result []Param, ok bool) {
 	for _, nds := range data {
 		values := nds.parts
-		result = nil
+		result = buf[:0]
 		found := true
 		for idx, value := range values {
-			if len(value) == 1 && value == "*" {
+			if len(value) >= 1 && value[0:1] == asterisk {
+				// named wildcard catches the rest of the path
+				if len(value) > 1 {
+					result = append(result, Param{Key: value, Value: join(parts[idx:])})
+				}
+				break
+			} else if idx >= len(parts) {
+				found = false
 				break
 			} else if value != parts[idx] && !(len(value) >= 1 && value[0:1] == ":") {
 				found = false
@@ -203,7 +242,7 @@ func parseParams(data records, parts []string) (handle Handle, result []Param, o
 			}
 		}
 		if found {
-			return nds.handle, result, true
+			return nds, result, true
 		}
 	}
 
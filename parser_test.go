@@ -73,6 +73,12 @@ var setOfRegistered = []registered{
 			c.Body(c.Get(":dir"))
 		},
 	},
+	{
+		"/assets/*filepath",
+		func(c *Control) {
+			c.Body("Asset " + c.Get("*filepath"))
+		},
+	},
 }
 
 var setOfExpected = []expected{
@@ -168,6 +174,22 @@ var setOfExpected = []expected{
 			{":dir", "js"},
 		},
 	},
+	{
+		"/assets/js/vendor/lib/app.js",
+		"Asset js/vendor/lib/app.js",
+		1,
+		[]Param{
+			{"*filepath", "js/vendor/lib/app.js"},
+		},
+	},
+	{
+		"/assets/",
+		"Asset ",
+		1,
+		[]Param{
+			{"*filepath", ""},
+		},
+	},
 }
 
 func TestParserRegisterGet(t *testing.T) {
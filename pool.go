@@ -0,0 +1,77 @@
+// Copyright 2015 Igor Dolzhikov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"net/http"
+	"sync"
+)
+
+// paramsCapacity is a default capacity of params buffer of pooled Controls
+const paramsCapacity = 8
+
+// releasedMessage is a panic message for usage of released Control
+const releasedMessage = "router: Control is used after the handler returned, use Control.Copy to keep request data"
+
+var controlPool = sync.Pool{
+	New: func() interface{} {
+		return &Control{params: make([]Param, 0, paramsCapacity)}
+	},
+}
+
+// acquire returns a Control from the pool prepared for the request
+func (r *Router) acquire(w http.ResponseWriter, req *http.Request) *Control {
+	c := controlPool.Get().(*Control)
+	c.reset(r, w, req)
+	return c
+}
+
+// release returns the Control into the pool, if Router.PoisonControls is set
+// it is poisoned instead and never reused
+func (r *Router) release(c *Control) {
+	if r.PoisonControls {
+		c.reset(nil, releasedWriter{}, nil)
+		c.released = true
+		return
+	}
+	c.reset(nil, nil, nil)
+	controlPool.Put(c)
+}
+
+// checkReleased panics if the Control is used after the handler returned
+func (c *Control) checkReleased() {
+	if c.released {
+		panic(releasedMessage)
+	}
+}
+
+// reset clears the Control with keeping of its buffers
+func (c *Control) reset(r *Router, w http.ResponseWriter, req *http.Request) {
+	if c.events != nil {
+		c.events.Close()
+	}
+	params, parts := c.params[:0], c.parts[:0]
+	*c = Control{Request: req, Writer: w, router: r, params: params, parts: parts}
+}
+
+// Copy returns a copy of the Control which may be used after the handler returned.
+// The Writer of the copy must not be used.
+func (c *Control) Copy() *Control {
+	c.checkReleased()
+	cp := *c
+	cp.Writer = releasedWriter{}
+	cp.params = append([]Param(nil), c.params...)
+	cp.parts = nil
+	cp.events = nil
+	cp.errorHeader.Errors = append([]Error(nil), c.errorHeader.Errors...)
+	return &cp
+}
+
+// releasedWriter panics on any usage
+type releasedWriter struct{}
+
+func (releasedWriter) Header() http.Header       { panic(releasedMessage) }
+func (releasedWriter) Write([]byte) (int, error) { panic(releasedMessage) }
+func (releasedWriter) WriteHeader(int)           { panic(releasedMessage) }
@@ -0,0 +1,121 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRouterZeroAllocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops items with race detector")
+	}
+	r := New()
+	r.GET(twentyColon, routerHandle)
+	req, _ := http.NewRequest("GET", twentyRoute, nil)
+	trw := httptest.NewRecorder()
+	r.ServeHTTP(trw, req)
+	if allocs := testing.AllocsPerRun(100, func() { r.ServeHTTP(trw, req) }); allocs != 0 {
+		t.Error("Expected", 0, "allocations, got", allocs)
+	}
+}
+
+func TestRouterControlReuse(t *testing.T) {
+	r := New()
+	r.Logger = func(c *Control) {
+		c.Code(http.StatusTeapot).Set(Param{Key: "logged", Value: "yes"})
+	}
+	r.GET("/users/:id", func(c *Control) {
+		if c.GetCode() != 0 || len(c.params) != 1 {
+			t.Error("Expected clean Control, got code", c.GetCode(), "params", c.params)
+		}
+		c.Body(c.Get(":id"))
+	})
+	for _, id := range []string{"1", "2"} {
+		if trw := testRequest(r, "GET", "/users/"+id, nil); trw.Body.String() != id {
+			t.Error("Expected", id, "got", trw.Body.String())
+		}
+	}
+}
+
+func TestRouterReleasedControl(t *testing.T) {
+	r := New()
+	r.PoisonControls = true
+	var retained, copied *Control
+	r.GET("/users/:id", func(c *Control) {
+		retained, copied = c, c.Copy()
+	})
+	testRequest(r, "GET", "/users/17", nil)
+	if copied.Get(":id") != "17" || copied.Param("id") != "17" {
+		t.Error("Expected", "17", "got", copied.Get(":id"))
+	}
+	accessors := map[string]func(){
+		"Get":       func() { retained.Get(":id") },
+		"Param":     func() { retained.Param("id") },
+		"Params":    func() { retained.Params() },
+		"GetAll":    func() { retained.GetAll("id") },
+		"Has":       func() { retained.Has("id") },
+		"Query":     func() { retained.Query() },
+		"Code":      func() { retained.Code(http.StatusOK) },
+		"Bind":      func() { retained.Bind(&struct{}{}) },
+		"SetCookie": func() { retained.SetCookie(&http.Cookie{Name: "a"}) },
+		"Cookie":    func() { retained.Cookie("a") },
+		"Paginate":  func() { retained.Paginate(10, 10) },
+	}
+	for name, accessor := range accessors {
+		func() {
+			defer func() {
+				if recovery := recover(); recovery != releasedMessage {
+					t.Error("Expected panic of", name, "got", recovery)
+				}
+			}()
+			accessor()
+		}()
+	}
+}
+
+func TestRouterReleasedControlDevelopment(t *testing.T) {
+	r := New()
+	r.Development = true
+	var retained *Control
+	r.GET("/users/:id", func(c *Control) {
+		retained = c
+	})
+	testRequest(r, "GET", "/users/17", nil)
+	// development mode does not poison Controls
+	if retained.released {
+		t.Error("Expected pooled Control")
+	}
+}
+
+func TestRouteMiddlewareComposedOnce(t *testing.T) {
+	r := New()
+	var wraps, calls int
+	middleware := func(next Handle) Handle {
+		wraps++
+		return func(c *Control) {
+			calls++
+			next(c)
+		}
+	}
+	r.GET(twentyColon, routerHandle).Use(middleware).Precondition(func(c *Control) (string, time.Time, error) {
+		return `"v1"`, time.Time{}, nil
+	})
+	req, _ := http.NewRequest("GET", twentyRoute, nil)
+	trw := httptest.NewRecorder()
+	r.ServeHTTP(trw, req)
+	wraps = 0
+	for idx := 0; idx < 3; idx++ {
+		r.ServeHTTP(trw, req)
+	}
+	if wraps != 0 || calls != 4 {
+		t.Error("Expected", 0, "wraps and", 4, "calls, got", wraps, calls)
+	}
+	if raceEnabled {
+		return
+	}
+	if allocs := testing.AllocsPerRun(100, func() { r.ServeHTTP(trw, req) }); allocs != 0 {
+		t.Error("Expected", 0, "allocations, got", allocs)
+	}
+}
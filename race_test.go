@@ -0,0 +1,8 @@
+//go:build race
+// +build race
+
+package router
+
+// raceEnabled is set when tests are run with race detector
+// which makes sync.Pool to drop items randomly
+const raceEnabled = true
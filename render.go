@@ -0,0 +1,329 @@
+// Copyright 2015 Igor Dolzhikov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Renderer encodes data of Control.Body into a format of the response
+type Renderer interface {
+	// ContentType returns a media type of the format, it is used in content negotiation
+	ContentType() string
+	// Render writes encoded data
+	Render(w io.Writer, data interface{}) error
+}
+
+// SuffixRenderer is a Renderer which may be selected by suffix
+// of URL path, e.g. ".xml" (see Router.FormatSuffix)
+type SuffixRenderer interface {
+	Renderer
+	Suffix() string
+}
+
+// DefaultRenderers returns renderers which are used if Router.Renderers is not set:
+// JSON only, which is rendered for any "Accept" header
+func DefaultRenderers() []Renderer {
+	return []Renderer{JSONRenderer{}}
+}
+
+// BuiltinRenderers returns all built-in renderers: JSON, XML, YAML, CSV and
+// URL encoded form, e.g. r.Renderers = router.BuiltinRenderers()
+func BuiltinRenderers() []Renderer {
+	return []Renderer{JSONRenderer{}, XMLRenderer{}, YAMLRenderer{}, CSVRenderer{}, FormRenderer{}}
+}
+
+// defaultRenderers are used by Controls without own renderers
+var defaultRenderers = DefaultRenderers()
+
+// xmlRoot is a name of the root element of XML responses
+const xmlRoot = "response"
+
+// JSONRenderer renders data in JSON format
+type JSONRenderer struct {
+	// Compact disables indentation
+	Compact bool
+}
+
+// ContentType returns MIMEJSON
+func (JSONRenderer) ContentType() string { return MIMEJSON }
+
+// Suffix returns ".json"
+func (JSONRenderer) Suffix() string { return ".json" }
+
+// Render writes data in JSON format
+func (j JSONRenderer) Render(w io.Writer, data interface{}) error {
+	var content []byte
+	var err error
+	if j.Compact {
+		content, err = json.Marshal(data)
+	} else {
+		content, err = json.MarshalIndent(data, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(content)
+	return err
+}
+
+// XMLRenderer renders objects as elements named by keys and items of arrays as "item" elements
+// inside of the "response" root element. Keys which are not valid names of elements are
+// rendered as "entry" elements with "key" attribute.
+type XMLRenderer struct {
+	// Compact disables indentation
+	Compact bool
+}
+
+// ContentType returns MIMEXML
+func (XMLRenderer) ContentType() string { return MIMEXML }
+
+// Suffix returns ".xml"
+func (XMLRenderer) Suffix() string { return ".xml" }
+
+// Render writes data in XML format
+func (x XMLRenderer) Render(w io.Writer, data interface{}) error {
+	n, err := marshalNode(data)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	indent := "\n"
+	if x.Compact {
+		indent = ""
+	}
+	writeXML(&buf, xmlRoot, n, indent)
+	if !x.Compact {
+		buf.WriteByte('\n')
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// YAMLRenderer renders data in YAML format
+type YAMLRenderer struct{}
+
+// ContentType returns MIMEYAML
+func (YAMLRenderer) ContentType() string { return MIMEYAML }
+
+// Suffix returns ".yaml"
+func (YAMLRenderer) Suffix() string { return ".yaml" }
+
+// Render writes data in YAML format
+func (YAMLRenderer) Render(w io.Writer, data interface{}) error {
+	content, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if content, err = jsonToYAML(content); err != nil {
+		return err
+	}
+	_, err = w.Write(content)
+	return err
+}
+
+// CSVRenderer renders items of an array as rows, other values as a single row.
+// Nested values are flattened into columns with dotted names, e.g. "address.city".
+type CSVRenderer struct{}
+
+// ContentType returns MIMECSV
+func (CSVRenderer) ContentType() string { return MIMECSV }
+
+// Suffix returns ".csv"
+func (CSVRenderer) Suffix() string { return ".csv" }
+
+// Render writes data in CSV format
+func (CSVRenderer) Render(w io.Writer, data interface{}) error {
+	n, err := marshalNode(data)
+	if err != nil {
+		return err
+	}
+	items := []*node{n}
+	if n.isArray {
+		items = n.items
+	}
+	var columns []string
+	index := make(map[string]int)
+	rows := make([]map[string]string, len(items))
+	for idx, item := range items {
+		row := make(map[string]string)
+		flatten(item, "", true, func(key, value string) {
+			if _, ok := index[key]; !ok {
+				index[key] = len(columns)
+				columns = append(columns, key)
+			}
+			row[key] = value
+		})
+		rows[idx] = row
+	}
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write(columns)
+	record := make([]string, len(columns))
+	for _, row := range rows {
+		for idx, column := range columns {
+			record[idx] = row[column]
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// FormRenderer renders flattened values in URL encoded form, items of arrays are repeated keys
+type FormRenderer struct{}
+
+// ContentType returns MIMEForm
+func (FormRenderer) ContentType() string { return MIMEForm }
+
+// Render writes data in URL encoded form
+func (FormRenderer) Render(w io.Writer, data interface{}) error {
+	n, err := marshalNode(data)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	flatten(n, "", false, func(key, value string) {
+		if buf.Len() > 0 {
+			buf.WriteByte('&')
+		}
+		buf.WriteString(url.QueryEscape(key))
+		buf.WriteByte('=')
+		buf.WriteString(url.QueryEscape(value))
+	})
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// replaceRenderer returns a copy of renderers where the renderer of the same content type is replaced
+func replaceRenderer(renderers []Renderer, renderer Renderer) []Renderer {
+	result := make([]Renderer, 0, len(renderers)+1)
+	replaced := false
+	for _, current := range renderers {
+		if current.ContentType() == renderer.ContentType() {
+			current, replaced = renderer, true
+		}
+		result = append(result, current)
+	}
+	if !replaced {
+		result = append(result, renderer)
+	}
+	return result
+}
+
+// marshalNode converts the data into ordered representation of its JSON encoding
+func marshalNode(data interface{}) (*node, error) {
+	content, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return decodeNode(content)
+}
+
+func writeXML(buf *bytes.Buffer, name string, n *node, indent string) {
+	open, closing := xmlTags(name)
+	switch {
+	case n.isMap && len(n.keys) > 0:
+		buf.WriteString(open)
+		for idx, key := range n.keys {
+			buf.WriteString(xmlIndent(indent))
+			writeXML(buf, key, n.values[idx], xmlIndent(indent))
+		}
+		buf.WriteString(indent)
+		buf.WriteString(closing)
+	case n.isArray && len(n.items) > 0:
+		buf.WriteString(open)
+		for _, item := range n.items {
+			buf.WriteString(xmlIndent(indent))
+			writeXML(buf, "item", item, xmlIndent(indent))
+		}
+		buf.WriteString(indent)
+		buf.WriteString(closing)
+	case string(n.scalar) == "null", n.isMap, n.isArray:
+		buf.WriteString(open[:len(open)-1])
+		buf.WriteString("/>")
+	default:
+		buf.WriteString(open)
+		xml.EscapeText(buf, []byte(n.text()))
+		buf.WriteString(closing)
+	}
+}
+
+// xmlIndent returns indentation of nested elements
+func xmlIndent(indent string) string {
+	if indent == "" {
+		return ""
+	}
+	return indent + "  "
+}
+
+// xmlTags returns opening and closing tags of the element
+func xmlTags(name string) (string, string) {
+	if isXMLName(name) {
+		return "<" + name + ">", "</" + name + ">"
+	}
+	var key bytes.Buffer
+	xml.EscapeText(&key, []byte(name))
+	return `<entry key="` + key.String() + `">`, "</entry>"
+}
+
+func isXMLName(name string) bool {
+	if name == "" || strings.HasPrefix(strings.ToLower(name), "xml") {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' {
+			continue
+		}
+		if i > 0 && (c >= '0' && c <= '9' || c == '-' || c == '.') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// flatten calls fn for every scalar value with a dotted key,
+// items of arrays are indexed if indexed is set
+func flatten(n *node, prefix string, indexed bool, fn func(key, value string)) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+	switch {
+	case n.isMap:
+		for idx, key := range n.keys {
+			flatten(n.values[idx], join(key), indexed, fn)
+		}
+	case n.isArray:
+		for idx, item := range n.items {
+			if indexed {
+				flatten(item, join(strconv.Itoa(idx)), indexed, fn)
+			} else {
+				flatten(item, prefix, indexed, fn)
+			}
+		}
+	default:
+		if prefix == "" {
+			prefix = "value"
+		}
+		fn(prefix, n.text())
+	}
+}
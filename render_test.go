@@ -0,0 +1,52 @@
+package router
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+)
+
+// testRenderer is a custom codec
+type testRenderer struct {
+	contentType string
+}
+
+func (t testRenderer) ContentType() string { return t.contentType }
+
+func (t testRenderer) Render(w io.Writer, data interface{}) error {
+	_, err := fmt.Fprintf(w, "%s:%v", t.contentType, data)
+	return err
+}
+
+func TestRouterRenderers(t *testing.T) {
+	r := New()
+	r.Renderers = replaceRenderer(DefaultRenderers(), testRenderer{contentType: MIMEJSON})
+	r.Renderers = append(r.Renderers, testRenderer{contentType: "application/msgpack"})
+	r.GET("/data", func(c *Control) {
+		c.Body(17)
+	})
+	r.GET("/compact", func(c *Control) {
+		c.CompactJSON(true).Body([]int{1, 2})
+	})
+	r.GET("/route", func(c *Control) {
+		c.Body(17)
+	}).Renderers(XMLRenderer{Compact: true})
+
+	if trw := testRequest(r, "GET", "/data", nil); trw.Body.String() != "application/json:17" {
+		t.Error("Expected", "application/json:17", "got", trw.Body.String())
+	}
+	trw := testRequest(r, "GET", "/data", map[string]string{"Accept": "application/msgpack"})
+	if trw.Body.String() != "application/msgpack:17" || trw.Header().Get("Content-Type") != "application/msgpack" {
+		t.Error("Expected", "application/msgpack:17", "got", trw.Body.String())
+	}
+	if trw := testRequest(r, "GET", "/compact", map[string]string{"Accept": MIMEJSON}); trw.Body.String() != "[1,2]" {
+		t.Error("Expected", "[1,2]", "got", trw.Body.String())
+	}
+	if trw := testRequest(r, "GET", "/route", nil); trw.Body.String() != "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<response>17</response>" {
+		t.Error("Expected XML response, got", trw.Body.String())
+	}
+	if trw := testRequest(r, "GET", "/route", map[string]string{"Accept": MIMEJSON}); trw.Code != http.StatusNotAcceptable {
+		t.Error("Expected", http.StatusNotAcceptable, "got", trw.Code)
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2015 Igor Dolzhikov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Redirect replies with the redirect to the location, relative locations are
+// resolved against the path of the request. The code must be one of
+// 300, 301, 302, 303, 307 or 308.
+func (c *Control) Redirect(code int, location string) error {
+	c.checkReleased()
+	switch code {
+	case http.StatusMultipleChoices, http.StatusMovedPermanently, http.StatusFound,
+		http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return fmt.Errorf("router: invalid redirect code %d", code)
+	}
+	http.Redirect(c.Writer, c.Request, location, code)
+	return nil
+}
+
+// RedirectRoute replies with the redirect to the named route (see Router.URL)
+func (c *Control) RedirectRoute(code int, name string, params ...Param) error {
+	c.checkReleased()
+	location, err := c.URL(name, params...)
+	if err != nil {
+		return err
+	}
+	return c.Redirect(code, location)
+}
+
+// URL builds the path of the named route of the router (see Router.URL)
+func (c *Control) URL(name string, params ...Param) (string, error) {
+	c.checkReleased()
+	if c.router == nil {
+		return "", fmt.Errorf("router: route %q is not found", name)
+	}
+	return c.router.URL(name, params...)
+}
+
+// SetCookie adds "Set-Cookie" header to the response, invalid cookies are not set
+func (c *Control) SetCookie(cookie *http.Cookie) *Control {
+	c.checkReleased()
+	http.SetCookie(c.Writer, cookie)
+	return c
+}
+
+// DeleteCookie tells the client to remove the cookie with the name and path
+func (c *Control) DeleteCookie(name, path string) *Control {
+	c.checkReleased()
+	return c.SetCookie(&http.Cookie{Name: name, Path: path, MaxAge: -1, Expires: time.Unix(1, 0)})
+}
+
+// Cookie returns the cookie of the request with the name
+func (c *Control) Cookie(name string) (*http.Cookie, bool) {
+	c.checkReleased()
+	cookie, err := c.Request.Cookie(name)
+	return cookie, err == nil
+}
+
+// File replies with the content of the named file of the file system.
+// Range requests and conditional requests by modification time are supported.
+func (c *Control) File(name string) {
+	c.checkReleased()
+	file, err := os.Open(name)
+	if err != nil {
+		c.fileError(err)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		c.fileError(err)
+		return
+	}
+	if info.IsDir() {
+		http.NotFound(c.Writer, c.Request)
+		return
+	}
+	c.serveContent(info.Name(), info.ModTime(), file)
+}
+
+// Attachment replies with the content which is downloaded by the client as the file
+// with the name. "Content-Length" and range requests are supported if the content
+// is io.ReadSeeker, e.g. *os.File or *bytes.Reader.
+func (c *Control) Attachment(content io.Reader, filename string) {
+	c.checkReleased()
+	c.Writer.Header().Set("Content-Disposition", contentDisposition("attachment", filename))
+	if seeker, ok := content.(io.ReadSeeker); ok {
+		c.serveContent(filename, time.Time{}, seeker)
+		return
+	}
+	header := c.Writer.Header()
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", "application/octet-stream")
+		if contentType := mime.TypeByExtension(filepath.Ext(filename)); contentType != "" {
+			header.Set("Content-Type", contentType)
+		}
+	}
+	if c.code > 0 {
+		c.Writer.WriteHeader(c.code)
+	}
+	if _, err := io.Copy(c.Writer, content); err != nil {
+		c.fail(OpWrite, err)
+	}
+}
+
+// serveContent writes the content with "Content-Length", ranges and validators,
+// the content type is detected by the extension of the name or the content
+func (c *Control) serveContent(name string, modified time.Time, content io.ReadSeeker) {
+	header := c.Writer.Header()
+	if c.etag != "" {
+		header.Set("ETag", c.etag)
+	}
+	if !c.lastModified.IsZero() {
+		modified = c.lastModified
+	}
+	http.ServeContent(c.Writer, c.Request, name, modified, content)
+}
+
+// fileError replies with the status of the error of the file system
+func (c *Control) fileError(err error) {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		http.NotFound(c.Writer, c.Request)
+	case errors.Is(err, fs.ErrPermission):
+		http.Error(c.Writer, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	default:
+		c.logError(err)
+		http.Error(c.Writer, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// contentDisposition returns "Content-Disposition" header with the file name,
+// non-ASCII names are encoded by RFC 6266 with ASCII fallback for old clients
+func contentDisposition(disposition, filename string) string {
+	filename = filepath.Base(filename)
+	var fallback strings.Builder
+	ascii := true
+	for _, r := range filename {
+		switch {
+		case r < 0x20 || r == 0x7f:
+			ascii = false
+		case r > 0x7f:
+			ascii = false
+			fallback.WriteByte('_')
+		case r == '"' || r == '\\':
+			fallback.WriteByte('\\')
+			fallback.WriteRune(r)
+		default:
+			fallback.WriteRune(r)
+		}
+	}
+	value := disposition + `; filename="` + fallback.String() + `"`
+	if !ascii {
+		value += "; filename*=UTF-8''" + encodeExtValue(filename)
+	}
+	return value
+}
+
+// encodeExtValue percent-encodes all bytes except attr-char of RFC 8187
+func encodeExtValue(value string) string {
+	const hex = "0123456789ABCDEF"
+	var buf strings.Builder
+	for idx := 0; idx < len(value); idx++ {
+		b := value[idx]
+		if 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9' ||
+			strings.IndexByte("!#$&+-.^_`|~", b) >= 0 {
+			buf.WriteByte(b)
+			continue
+		}
+		buf.WriteByte('%')
+		buf.WriteByte(hex[b>>4])
+		buf.WriteByte(hex[b&15])
+	}
+	return buf.String()
+}
@@ -0,0 +1,122 @@
+package router
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestControlRedirect(t *testing.T) {
+	r := New()
+	r.GET("/users/:id", func(c *Control) {}).Name("user")
+	r.GET("/old/:id", func(c *Control) {
+		c.RedirectRoute(http.StatusMovedPermanently, "user", Param{Key: "id", Value: c.Get(":id")})
+	})
+	r.GET("/docs/intro", func(c *Control) {
+		c.Redirect(http.StatusFound, "guide")
+	})
+	r.GET("/invalid", func(c *Control) {
+		if err := c.Redirect(http.StatusOK, "/"); err == nil {
+			t.Error("Expected error of invalid code")
+		}
+	})
+
+	trw := testRequest(r, "GET", "/old/42", nil)
+	if trw.Code != http.StatusMovedPermanently || trw.Header().Get("Location") != "/users/42" {
+		t.Error("Expected redirect to /users/42, got", trw.Code, trw.Header().Get("Location"))
+	}
+	trw = testRequest(r, "GET", "/docs/intro", nil)
+	if trw.Code != http.StatusFound || trw.Header().Get("Location") != "/docs/guide" {
+		t.Error("Expected redirect to /docs/guide, got", trw.Code, trw.Header().Get("Location"))
+	}
+	if trw = testRequest(r, "GET", "/invalid", nil); trw.Header().Get("Location") != "" {
+		t.Error("Expected no redirect, got", trw.Header().Get("Location"))
+	}
+}
+
+func TestControlCookies(t *testing.T) {
+	r := New()
+	r.GET("/cookies", func(c *Control) {
+		cookie, ok := c.Cookie("session")
+		if !ok || cookie.Value != "abc" {
+			t.Error("Expected", "abc", "got", cookie)
+		}
+		if _, ok := c.Cookie("unknown"); ok {
+			t.Error("Expected unknown cookie to be missing")
+		}
+		c.SetCookie(&http.Cookie{Name: "theme", Value: "dark", HttpOnly: true}).DeleteCookie("session", "/")
+	})
+
+	trw := testRequest(r, "GET", "/cookies", map[string]string{"Cookie": "session=abc"})
+	cookies := trw.Result().Cookies()
+	if len(cookies) != 2 || cookies[0].Value != "dark" || !cookies[0].HttpOnly || cookies[1].MaxAge >= 0 {
+		t.Error("Expected theme cookie and deleted session, got", trw.Header()["Set-Cookie"])
+	}
+}
+
+func TestControlFile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "report.txt")
+	if err := os.WriteFile(name, []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	r.GET("/file/:name", func(c *Control) {
+		c.File(filepath.Join(dir, c.Get(":name")))
+	})
+
+	trw := testRequest(r, "GET", "/file/report.txt", nil)
+	if trw.Code != http.StatusOK || trw.Body.String() != "0123456789" ||
+		trw.Header().Get("Content-Length") != "10" || trw.Header().Get("Content-Type") != "text/plain; charset=utf-8" {
+		t.Error("Expected file, got", trw.Code, trw.Header(), trw.Body.String())
+	}
+	trw = testRequest(r, "GET", "/file/report.txt", map[string]string{"Range": "bytes=2-4"})
+	if trw.Code != http.StatusPartialContent || trw.Body.String() != "234" {
+		t.Error("Expected", http.StatusPartialContent, "234", "got", trw.Code, trw.Body.String())
+	}
+	trw = testRequest(r, "GET", "/file/report.txt", map[string]string{"If-Modified-Since": trw.Header().Get("Last-Modified")})
+	if trw.Code != http.StatusNotModified {
+		t.Error("Expected", http.StatusNotModified, "got", trw.Code)
+	}
+	if trw = testRequest(r, "GET", "/file/missing.txt", nil); trw.Code != http.StatusNotFound {
+		t.Error("Expected", http.StatusNotFound, "got", trw.Code)
+	}
+}
+
+func TestControlAttachment(t *testing.T) {
+	r := New()
+	r.GET("/report", func(c *Control) {
+		c.Attachment(bytes.NewReader([]byte(`{"a":1}`)), "report.json")
+	})
+	r.GET("/unicode", func(c *Control) {
+		c.Attachment(strings.NewReader("data"), "отчёт 2020.csv")
+	})
+	r.GET("/stream", func(c *Control) {
+		c.Attachment(ioutil.NopCloser(strings.NewReader("streamed")), `my "file".bin`)
+	})
+
+	trw := testRequest(r, "GET", "/report", nil)
+	if trw.Header().Get("Content-Disposition") != `attachment; filename="report.json"` ||
+		trw.Header().Get("Content-Length") != "7" || trw.Header().Get("Content-Type") != MIMEJSON {
+		t.Error("Expected attachment headers, got", trw.Header())
+	}
+	if trw = testRequest(r, "GET", "/report", map[string]string{"Range": "bytes=5-"}); trw.Body.String() != "1}" {
+		t.Error("Expected", "1}", "got", trw.Body.String())
+	}
+
+	trw = testRequest(r, "GET", "/unicode", nil)
+	expected := `attachment; filename="_____ 2020.csv"; filename*=UTF-8''%D0%BE%D1%82%D1%87%D1%91%D1%82%202020.csv`
+	if disposition := trw.Header().Get("Content-Disposition"); disposition != expected {
+		t.Error("Expected", expected, "got", disposition)
+	}
+
+	trw = testRequest(r, "GET", "/stream", nil)
+	if trw.Header().Get("Content-Disposition") != `attachment; filename="my \"file\".bin"` ||
+		trw.Header().Get("Content-Type") != "application/octet-stream" || trw.Body.String() != "streamed" {
+		t.Error("Expected streamed attachment, got", trw.Header(), trw.Body.String())
+	}
+}
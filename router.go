@@ -32,7 +32,6 @@ Simplest example (serve static route):
 		r.Listen(":8888")
 	}
 
-
 Check it:
 
 	curl -i http://localhost:8888/hello
@@ -121,15 +120,17 @@ Use google json style `https://google.github.io/styleguide/jsoncstyleguide.xml`
 		r.Listen(":8888")
 	}
 
-
 Go Router
 */
 package router
 
 import (
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 )
 
 // Router represents a multiplexer for HTTP requests.
@@ -152,15 +153,131 @@ type Router struct {
 
 	// Logger activates logging user function for each requests
 	Logger Handle
+
+	// Sources defines order of lookup of values in Control.Get, GetAll and Has.
+	// If it is not set, path parameters are checked first, then the query string.
+	Sources []Source
+
+	// BareParams stores keys of path parameters without ":" or "*" prefix,
+	// e.g. "name" instead of ":name". Control.Get accepts both forms of the key.
+	BareParams bool
+
+	// NotAcceptable is called by Control.Body when none of response formats
+	// are acceptable by the client. If it is not set, "406 Not Acceptable" is returned.
+	// The handler must not render data by Body except strings.
+	NotAcceptable Handle
+
+	// ErrorHandler is called by Control.Body with *ResponseError when data cannot be
+	// rendered or the response cannot be written, e.g. because of broken pipe.
+	// If it is not set, the error is logged and render errors are answered by
+	// "500 Internal Server Error" with ErrorHeader in the negotiated format.
+	ErrorHandler func(*Control, error)
+
+	// ErrorLog logs errors of responses, if it is not set, the standard logger is used
+	ErrorLog *log.Logger
+
+	// Renderers defines formats of Control.Body in order of preference.
+	// If it is not set, DefaultRenderers are used.
+	Renderers []Renderer
+
+	// FormatSuffix allows to select a format of the response by suffix
+	// of URL path, e.g. "/users/1.xml" or "/users.csv", instead of "Accept" header
+	FormatSuffix bool
+
+	// ETag defines whether Control.Body generates ETags of responses, it may be
+	// changed for a route by Route.ETag. Generated and explicit ETags, as well as
+	// Last-Modified, are used to answer conditional GET requests with "304 Not Modified".
+	ETag ETagMode
+
+	// Compression defines compression of responses rendered by Control.Body.
+	// If it is not set, DefaultCompression is used.
+	Compression *Compression
+
+	// Upgrader defines options of WebSocket connections opened by Control.Upgrade.
+	// If it is not set, default options are used.
+	Upgrader *Upgrader
+
+	// PoisonControls makes Controls panic on any usage after the handler returned
+	// instead of returning them into the pool, it helps to catch handlers which
+	// keep the Control, e.g. in a goroutine
+	PoisonControls bool
+
+	// Development activates features which help to debug an application,
+	// e.g. MatchHeader with matched pattern in every response
+	Development bool
+
+	// List of registered routes in order of registration
+	routes []*Route
+
+	// mu protects handlers and routes which may be reloaded on a live router
+	mu sync.RWMutex
+
+	// spa serves single-page application for unknown paths
+	spa *SPA
+
+	// templates are rendered by Control.HTML
+	templates *Templates
 }
 
 // Handle type is aliased to type of handler function.
 type Handle func(*Control)
 
+// Middleware wraps a handler to run some code before and/or after it.
+type Middleware func(Handle) Handle
+
 // Route type contains information about HTTP method and path
 type Route struct {
 	Method string
 	Path   string
+
+	// handle is the registered handler of the route
+	handle Handle
+
+	// middleware wraps the handler, first is outermost
+	middleware []Middleware
+
+	// chain is the handler wrapped by preconditions and middleware,
+	// it is composed when the route is created or changed
+	chain Handle
+
+	// router protects changes of the published route
+	router *Router
+
+	// name is used to build URLs of the route
+	name string
+
+	// source is a name of route file the route was loaded from
+	source string
+
+	// disabled route is known but not served
+	disabled bool
+
+	// Names of the handler and middleware in a Registry
+	handlerName     string
+	middlewareNames []string
+
+	// metadata is a set of user defined key/value data
+	metadata map[string]string
+
+	// renderers are used in content negotiation instead of renderers of the router
+	renderers []Renderer
+
+	// etag is a mode of generated ETags instead of the router mode if hasETag is set
+	etag    ETagMode
+	hasETag bool
+
+	// state of the resource for preconditions of requests
+	state         StateFunc
+	stateRequired bool
+
+	// Documentation of the route (see OpenAPI)
+	summary      string
+	description  string
+	tags         []string
+	request      interface{}
+	responses    map[int]interface{}
+	errors       []int
+	undocumented bool
 }
 
 // New it returns a new multiplexer (Router).
@@ -169,51 +286,172 @@ func New() *Router {
 }
 
 // GET is a shortcut for Router Handle("GET", path, handle)
-func (r *Router) GET(path string, h Handle) {
-	r.Handle("GET", path, h)
+func (r *Router) GET(path string, h Handle) *Route {
+	return r.Handle("GET", path, h)
 }
 
 // POST is a shortcut for Router Handle("POST", path, handle)
-func (r *Router) POST(path string, h Handle) {
-	r.Handle("POST", path, h)
+func (r *Router) POST(path string, h Handle) *Route {
+	return r.Handle("POST", path, h)
 }
 
 // PUT is a shortcut for Router Handle("PUT", path, handle)
-func (r *Router) PUT(path string, h Handle) {
-	r.Handle("PUT", path, h)
+func (r *Router) PUT(path string, h Handle) *Route {
+	return r.Handle("PUT", path, h)
 }
 
 // DELETE is a shortcut for Router Handle("DELETE", path, handle)
-func (r *Router) DELETE(path string, h Handle) {
-	r.Handle("DELETE", path, h)
+func (r *Router) DELETE(path string, h Handle) *Route {
+	return r.Handle("DELETE", path, h)
 }
 
 // HEAD is a shortcut for Router Handle("HEAD", path, handle)
-func (r *Router) HEAD(path string, h Handle) {
-	r.Handle("HEAD", path, h)
+func (r *Router) HEAD(path string, h Handle) *Route {
+	return r.Handle("HEAD", path, h)
 }
 
 // OPTIONS is a shortcut for Router Handle("OPTIONS", path, handle)
-func (r *Router) OPTIONS(path string, h Handle) {
-	r.Handle("OPTIONS", path, h)
+func (r *Router) OPTIONS(path string, h Handle) *Route {
+	return r.Handle("OPTIONS", path, h)
 }
 
 // PATCH is a shortcut for router.Handle("PATCH", path, handle)
-func (r *Router) PATCH(path string, handle Handle) {
-	r.Handle("PATCH", path, handle)
+func (r *Router) PATCH(path string, handle Handle) *Route {
+	return r.Handle("PATCH", path, handle)
 }
 
 // Handle registers a new request handle with the given path and method.
-func (r *Router) Handle(method, path string, h Handle) {
-	if r.handlers[method] == nil {
-		r.handlers[method] = newParser()
+// It returns the registered Route which may be used to describe it.
+func (r *Router) Handle(method, path string, h Handle) *Route {
+	route := &Route{Method: method, Path: path, handle: h, router: r}
+	route.compose()
+	r.mu.Lock()
+	r.add(route)
+	r.routes = append(r.routes, route)
+	r.mu.Unlock()
+
+	return route
+}
+
+// add registers the route in a parser of the route method
+func (r *Router) add(route *Route) {
+	if r.handlers[route.Method] == nil {
+		r.handlers[route.Method] = newParser()
 	}
-	r.handlers[method].register(path, h)
+	r.handlers[route.Method].add(route)
+}
+
+// Name sets the name which is used to build URLs of the route by Router.URL
+func (rt *Route) Name(name string) *Route {
+	rt.name = name
+	return rt
+}
+
+// URL builds the path of the named route with the parameters, e.g.
+// r.URL("user", Param{Key: "id", Value: "1"}) returns "/users/1" for "/users/:id".
+// Keys of parameters may be given with or without ":" and "*" prefixes.
+func (r *Router) URL(name string, params ...Param) (string, error) {
+	r.mu.RLock()
+	var route *Route
+	for _, rt := range r.routes {
+		if rt.name == name && !rt.disabled {
+			route = rt
+			break
+		}
+	}
+	r.mu.RUnlock()
+	if route == nil {
+		return "", fmt.Errorf("router: route %q is not found", name)
+	}
+	parts, _ := split(route.Path)
+	for idx, part := range parts {
+		if !strings.HasPrefix(part, ":") && !strings.HasPrefix(part, asterisk) {
+			continue
+		}
+		value, ok := "", false
+		for _, param := range params {
+			if bareParamKey(param.Key) == bareParamKey(part) {
+				value, ok = param.Value, true
+				break
+			}
+		}
+		if !ok {
+			return "", fmt.Errorf("router: parameter %q of route %q is missing", part, name)
+		}
+		if strings.HasPrefix(part, asterisk) {
+			// wildcard keeps slashes of the rest of the path
+			segments := strings.Split(strings.TrimPrefix(value, "/"), "/")
+			for i := range segments {
+				segments[i] = url.PathEscape(segments[i])
+			}
+			parts[idx] = strings.Join(segments, "/")
+		} else {
+			parts[idx] = url.PathEscape(value)
+		}
+	}
+	return "/" + strings.Join(parts, "/"), nil
+}
+
+// Use adds middleware which wraps the route handler,
+// the first one is called first
+func (rt *Route) Use(middleware ...Middleware) *Route {
+	rt.lock()
+	defer rt.unlock()
+	rt.middleware = append(rt.middleware, middleware...)
+	rt.compose()
+	return rt
+}
+
+// Renderers sets renderers which are offered in content negotiation
+// of Control.Body instead of renderers of the router
+func (rt *Route) Renderers(renderers ...Renderer) *Route {
+	rt.renderers = renderers
+	return rt
+}
+
+// SetMeta adds user defined key/value data to the route
+func (rt *Route) SetMeta(key, value string) *Route {
+	if rt.metadata == nil {
+		rt.metadata = make(map[string]string)
+	}
+	rt.metadata[key] = value
+	return rt
+}
+
+// Meta returns user defined value associated with the key
+func (rt *Route) Meta(key string) string {
+	return rt.metadata[key]
+}
+
+// lock locks the router of the route which may be already served
+func (rt *Route) lock() {
+	if rt.router != nil {
+		rt.router.mu.Lock()
+	}
+}
+
+// unlock unlocks the router of the route
+func (rt *Route) unlock() {
+	if rt.router != nil {
+		rt.router.mu.Unlock()
+	}
+}
+
+// compose wraps the route handler by preconditions and the route middleware
+func (rt *Route) compose() {
+	handle := rt.handle
+	if rt.state != nil {
+		handle = checkPreconditions(handle, rt.state, rt.stateRequired)
+	}
+	for idx := len(rt.middleware) - 1; idx >= 0; idx-- {
+		handle = rt.middleware[idx](handle)
+	}
+	rt.chain = handle
 }
 
 // Handler allows the usage of an http.Handler as a request handle.
-func (r *Router) Handler(method, path string, handler http.Handler) {
-	r.Handle(method, path,
+func (r *Router) Handler(method, path string, handler http.Handler) *Route {
+	return r.Handle(method, path,
 		func(c *Control) {
 			handler.ServeHTTP(c.Writer, c.Request)
 		},
@@ -221,8 +459,8 @@ func (r *Router) Handler(method, path string, handler http.Handler) {
 }
 
 // HandlerFunc allows the usage of an http.HandlerFunc as a request handle.
-func (r *Router) HandlerFunc(method, path string, handler http.HandlerFunc) {
-	r.Handle(method, path,
+func (r *Router) HandlerFunc(method, path string, handler http.HandlerFunc) *Route {
+	return r.Handle(method, path,
 		func(c *Control) {
 			handler(c.Writer, c.Request)
 		},
@@ -231,14 +469,22 @@ func (r *Router) HandlerFunc(method, path string, handler http.HandlerFunc) {
 
 // Lookup returns handler and URL parameters that associated with path.
 func (r *Router) Lookup(method, path string) (Handle, []Param, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	if parser := r.handlers[method]; parser != nil {
-		return parser.get(path)
+		handle, params, ok := parser.get(path)
+		if r.BareParams {
+			trimParamKeys(params)
+		}
+		return handle, params, ok
 	}
 	return nil, nil, false
 }
 
 // AllowedMethods returns list of allowed methods
 func (r *Router) AllowedMethods(path string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	var allowed []string
 	for method, parser := range r.handlers {
 		if _, _, ok := parser.get(path); ok {
@@ -258,39 +504,56 @@ func (r *Router) Listen(hostPort string) {
 
 // ServeHTTP implements http.Handler interface.
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	c := r.acquire(w, req)
 	defer func() {
 		if recovery := recover(); recovery != nil {
 			if r.PanicHandler != nil {
-				c := &Control{Request: req, Writer: w}
+				c.reset(r, w, req)
 				r.PanicHandler(c)
 			} else {
 				log.Println("Recovered in handler:", req.Method, req.URL.Path)
 			}
 		}
+		r.release(c)
 	}()
 	if r.Logger != nil {
-		c := &Control{Request: req, Writer: w}
 		r.Logger(c)
+		c.reset(r, w, req)
 	}
-	if _, ok := r.handlers[req.Method]; ok {
-		if handle, params, ok := r.handlers[req.Method].get(req.URL.Path); ok {
-			c := &Control{Request: req, Writer: w}
-			if len(params) > 0 {
-				c.params = append(c.params, params...)
-			}
-			if r.CustomHandler != nil {
-				r.CustomHandler(handle)(c)
-			} else {
-				handle(c)
-			}
-			return
+	r.mu.RLock()
+	var rec *record
+	var handle Handle
+	var ok bool
+	if parser := r.handlers[req.Method]; parser != nil {
+		rec, c.parts, c.params, c.format, ok = r.lookup(parser, req.URL.Path, c.parts, c.params)
+		if ok {
+			handle = rec.route.chain
+		}
+	}
+	r.mu.RUnlock()
+	if ok {
+		c.route = rec.route
+		if r.BareParams {
+			trimParamKeys(c.params)
+		}
+		if r.Development {
+			w.Header().Set(MatchHeader, rec.pattern)
 		}
+		if r.CustomHandler != nil {
+			r.CustomHandler(handle)(c)
+		} else {
+			handle(c)
+		}
+		return
 	}
+	c.params = c.params[:0]
 	allowed := r.AllowedMethods(req.URL.Path)
 
 	if len(allowed) == 0 {
+		if r.spa != nil && r.spa.serve(c) {
+			return
+		}
 		if r.NotFound != nil {
-			c := &Control{Request: req, Writer: w}
 			r.NotFound(c)
 		} else {
 			http.NotFound(w, req)
@@ -302,8 +565,41 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 }
 
+// lookup finds a record for the path. If FormatSuffix is set, the path without
+// the format suffix is tried first and the renderer of the suffix is returned.
+// Catch-all routes get the path as is, e.g. "/static/data.json" is a file.
+func (r *Router) lookup(parser *parser, path string, parts []string, params []Param) (*record, []string, []Param, Renderer, bool) {
+	if r.FormatSuffix {
+		if stripped, format := suffixRenderer(path, r.renderList()); format != nil {
+			rec, parts, params, ok := parser.lookup(stripped, parts, params)
+			if ok && !rec.catchAll() {
+				return rec, parts, params, format, true
+			}
+		}
+	}
+	rec, parts, params, ok := parser.lookup(path, parts, params)
+	return rec, parts, params, nil, ok
+}
+
+// renderList returns renderers of the router or default ones
+func (r *Router) renderList() []Renderer {
+	if r.Renderers != nil {
+		return r.Renderers
+	}
+	return defaultRenderers
+}
+
+// trimParamKeys removes prefixes of the keys in place
+func trimParamKeys(params []Param) {
+	for idx := range params {
+		params[idx].Key = bareParamKey(params[idx].Key)
+	}
+}
+
 // Routes returns list of registered HTTP methods with path
 func (r *Router) Routes() []Route {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	var rs []Route
 	for method, parser := range r.handlers {
 		for _, path := range parser.routes() {
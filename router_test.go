@@ -11,6 +11,16 @@ import (
 
 var r = New()
 
+func testRequest(r *Router, method, path string, header map[string]string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, nil)
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+	trw := httptest.NewRecorder()
+	r.ServeHTTP(trw, req)
+	return trw
+}
+
 func TestRouterRegisterHandlers(t *testing.T) {
 
 	// Create new Router
@@ -233,3 +243,51 @@ func TestRouterPanic(t *testing.T) {
 		t.Error("Expected", http.StatusInternalServerError, "got", trw.Code)
 	}
 }
+
+func TestRouterBareParams(t *testing.T) {
+	r := New()
+	r.BareParams = true
+	r.GET("/users/:name/*path", func(c *Control) {
+		c.Body(c.Get("name") + " " + c.Get(":name") + " " + c.Param("name") + " " + c.Get("*path"))
+	})
+	req, err := http.NewRequest("GET", "/users/Jane/docs/a.txt?name=Joe", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	trw := httptest.NewRecorder()
+	r.ServeHTTP(trw, req)
+	if trw.Body.String() != "Jane Jane Jane docs/a.txt" {
+		t.Error("Expected", "Jane Jane Jane docs/a.txt", "got", trw.Body.String())
+	}
+	if _, params, ok := r.Lookup("GET", "/users/Jane/a"); !ok || params[0].Key != "name" {
+		t.Error("Expected key", "name", "got", params)
+	}
+}
+
+func TestRouterURL(t *testing.T) {
+	r := New()
+	r.GET("/", func(c *Control) {}).Name("home")
+	r.GET("/users/:id/posts/:post", func(c *Control) {}).Name("post")
+	r.GET("/static/*filepath", func(c *Control) {}).Name("static")
+
+	tests := []struct {
+		name     string
+		params   []Param
+		expected string
+	}{
+		{"home", nil, "/"},
+		{"post", []Param{{Key: "id", Value: "1"}, {Key: ":post", Value: "a b/c"}}, "/users/1/posts/a%20b%2Fc"},
+		{"static", []Param{{Key: "filepath", Value: "/css/app 1.css"}}, "/static/css/app%201.css"},
+	}
+	for _, test := range tests {
+		if url, err := r.URL(test.name, test.params...); err != nil || url != test.expected {
+			t.Error("Expected", test.expected, "got", url, err)
+		}
+	}
+	if _, err := r.URL("post", Param{Key: "id", Value: "1"}); err == nil {
+		t.Error("Expected error of missing parameter")
+	}
+	if _, err := r.URL("unknown"); err == nil {
+		t.Error("Expected error of unknown route")
+	}
+}
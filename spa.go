@@ -0,0 +1,77 @@
+// Copyright 2015 Igor Dolzhikov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// SPA serves a single-page application for GET and HEAD requests
+// which are not matched by any of registered routes
+type SPA struct {
+	// Index is a file which is served for client routes
+	Index string
+
+	// Exclude contains path prefixes which are not served by SPA,
+	// e.g. "/api/", unknown paths with these prefixes are handled by NotFound
+	Exclude []string
+
+	files *FileServer
+}
+
+// ServeSPA serves files of a single-page application from the file system.
+// Unknown paths without extension which are requested with "Accept: text/html"
+// are considered as client routes and get the index file.
+func (r *Router) ServeSPA(fsys fs.FS) *SPA {
+	r.spa = &SPA{
+		Index: "index.html",
+		files: &FileServer{FS: fsys, Precompressed: true, router: r},
+	}
+	return r.spa
+}
+
+// serve writes a file or the index file, it returns false if the request is not served
+func (spa *SPA) serve(c *Control) bool {
+	if c.Request.Method != "GET" && c.Request.Method != "HEAD" {
+		return false
+	}
+	urlPath := c.Request.URL.Path
+	for _, prefix := range spa.Exclude {
+		if strings.HasPrefix(urlPath, prefix) || urlPath+"/" == prefix {
+			return false
+		}
+	}
+	if name, ok := cleanFilePath(urlPath); ok {
+		if info, err := fs.Stat(spa.files.FS, name); err == nil && !info.IsDir() {
+			spa.files.serveFile(c, name, info)
+			return true
+		}
+	}
+	if path.Ext(urlPath) != "" || !acceptsHTML(c.Request.Header.Get("Accept")) {
+		return false
+	}
+	info, err := fs.Stat(spa.files.FS, spa.Index)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	// client routes must always get a fresh index
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	spa.files.serveFile(c, spa.Index, info)
+
+	return true
+}
+
+// acceptsHTML checks whether "text/html" is explicitly acceptable, wildcards
+// are sent by API clients which must not get the index
+func acceptsHTML(accept string) bool {
+	for _, r := range parseAccept(accept) {
+		if r.mediaType == "text/html" && r.quality > 0 {
+			return true
+		}
+	}
+	return false
+}
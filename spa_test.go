@@ -0,0 +1,52 @@
+package router
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRouterServeSPA(t *testing.T) {
+	r := New()
+	r.GET("/api/users", func(c *Control) {
+		c.Body([]string{"John"})
+	})
+	r.NotFound = func(c *Control) {
+		c.Code(http.StatusNotFound).Body(map[string]string{"error": "not found"})
+	}
+	spa := r.ServeSPA(testFS)
+	spa.Exclude = []string{"/api/"}
+	html := map[string]string{"Accept": "text/html,application/xhtml+xml"}
+
+	if trw := testRequest(r, "GET", "/api/users", html); trw.Body.String() != "[\n  \"John\"\n]" {
+		t.Error("Expected API response, got", trw.Body.String())
+	}
+	if trw := testRequest(r, "GET", "/css/app.css", nil); trw.Body.String() != "body { color: red; }" {
+		t.Error("Expected", "body { color: red; }", "got", trw.Body.String())
+	}
+	trw := testRequest(r, "GET", "/users/17/profile", html)
+	if trw.Body.String() != "<h1>Home</h1>" {
+		t.Error("Expected", "<h1>Home</h1>", "got", trw.Body.String())
+	}
+	if trw.Header().Get("Cache-Control") != "no-cache" {
+		t.Error("Expected", "no-cache", "got", trw.Header().Get("Cache-Control"))
+	}
+	if trw := testRequest(r, "GET", "/", html); trw.Body.String() != "<h1>Home</h1>" {
+		t.Error("Expected", "<h1>Home</h1>", "got", trw.Body.String())
+	}
+
+	// unknown API paths, assets and non-HTML requests are not found
+	for _, path := range []string{"/api/unknown", "/api", "/js/missing.js"} {
+		trw := testRequest(r, "GET", path, html)
+		if trw.Code != http.StatusNotFound || trw.Header().Get("Content-type") != MIMEJSON {
+			t.Error("Expected JSON", http.StatusNotFound, "for", path, "got", trw.Code)
+		}
+	}
+	for _, accept := range []string{"text/html;q=0, application/json", "*/*", "Text/HTML ; q=0.0"} {
+		if trw := testRequest(r, "GET", "/users/17", map[string]string{"Accept": accept}); trw.Code != http.StatusNotFound {
+			t.Error("Expected", http.StatusNotFound, "for", accept, "got", trw.Code)
+		}
+	}
+	if trw := testRequest(r, "GET", "/users/17", map[string]string{"Accept": "application/json"}); trw.Code != http.StatusNotFound {
+		t.Error("Expected", http.StatusNotFound, "got", trw.Code)
+	}
+}
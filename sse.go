@@ -0,0 +1,209 @@
+// Copyright 2015 Igor Dolzhikov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MIMEEventStream - "Content-type" for server-sent events
+const MIMEEventStream = "text/event-stream"
+
+// SSEHeartbeat is a period of heartbeat comments which keep idle event streams
+// alive through proxies, zero disables heartbeats
+var SSEHeartbeat = 15 * time.Second
+
+// EventStream writes server-sent events into the response
+type EventStream struct {
+	writer   io.Writer
+	flusher  http.Flusher
+	renderer Renderer
+	ctx      context.Context
+
+	// lastEventID is an ID of the last event received by the client before reconnection
+	lastEventID string
+
+	// mu serializes writes of events and heartbeats
+	mu     sync.Mutex
+	err    error
+	closed bool
+	stop   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// SSE starts an event stream. The response is never compressed, data of events
+// which are not strings is encoded by the JSON renderer of the Control.
+// The stream is closed when the handler returns, so events must be sent from the handler:
+//
+//	events := c.SSE()
+//	for {
+//		select {
+//		case <-events.Done():
+//			return
+//		case update := <-updates:
+//			events.Send("update", update.ID, update)
+//		}
+//	}
+func (c *Control) SSE() *EventStream {
+	c.checkReleased()
+	if c.events != nil {
+		return c.events
+	}
+	header := c.Writer.Header()
+	header.Set("Content-type", MIMEEventStream)
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	header.Del("Content-Encoding")
+	header.Del("Content-Length")
+	s := &EventStream{
+		writer:      c.Writer,
+		renderer:    JSONRenderer{Compact: true},
+		ctx:         c.Request.Context(),
+		lastEventID: c.Request.Header.Get("Last-Event-ID"),
+		stop:        make(chan struct{}),
+	}
+	s.flusher, _ = c.Writer.(http.Flusher)
+	// custom JSON codecs are used as is, the built-in one is always compact
+	for _, renderer := range c.renderList() {
+		if _, builtin := renderer.(JSONRenderer); renderer.ContentType() == MIMEJSON && !builtin {
+			s.renderer = renderer
+			break
+		}
+	}
+	code := c.code
+	if code == 0 {
+		code = http.StatusOK
+	}
+	c.Writer.WriteHeader(code)
+	s.flush()
+	if SSEHeartbeat > 0 {
+		s.wg.Add(1)
+		go s.heartbeat(SSEHeartbeat)
+	}
+	c.events = s
+
+	return s
+}
+
+// LastEventID returns a value of "Last-Event-ID" header which is sent
+// by the client on reconnection to resume the stream
+func (s *EventStream) LastEventID() string {
+	return s.lastEventID
+}
+
+// Done returns a channel which is closed when the client disconnects
+func (s *EventStream) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// Send writes the event, event name and id are optional. Strings and byte slices
+// are sent as is, other data is encoded by the renderer. It returns an error
+// if the client disconnected or the stream is closed.
+func (s *EventStream) Send(event, id string, data interface{}) error {
+	var content []byte
+	switch value := data.(type) {
+	case string:
+		content = []byte(value)
+	case []byte:
+		content = value
+	default:
+		var buf bytes.Buffer
+		if err := s.renderer.Render(&buf, data); err != nil {
+			return err
+		}
+		content = bytes.TrimRight(buf.Bytes(), "\n")
+	}
+	var buf bytes.Buffer
+	writeEventField(&buf, "event", event)
+	writeEventField(&buf, "id", id)
+	for _, line := range strings.Split(string(content), "\n") {
+		buf.WriteString("data: ")
+		buf.WriteString(strings.TrimSuffix(line, "\r"))
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+	return s.write(buf.Bytes())
+}
+
+// Retry tells the client a delay of reconnection
+func (s *EventStream) Retry(delay time.Duration) error {
+	return s.write([]byte("retry: " + strconv.FormatInt(int64(delay/time.Millisecond), 10) + "\n\n"))
+}
+
+// Close stops heartbeats, it is called automatically when the handler returns
+func (s *EventStream) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.stop)
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// writeEventField writes a field of the event if the value is not empty,
+// line breaks are not allowed in event names and ids
+func writeEventField(buf *bytes.Buffer, name, value string) {
+	if value == "" {
+		return
+	}
+	value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+	buf.WriteString(name)
+	buf.WriteString(": ")
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+func (s *EventStream) write(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.err != nil:
+		return s.err
+	case s.closed:
+		return io.ErrClosedPipe
+	case s.ctx.Err() != nil:
+		s.err = s.ctx.Err()
+		return s.err
+	}
+	if _, s.err = s.writer.Write(data); s.err == nil {
+		s.flush()
+	}
+	return s.err
+}
+
+func (s *EventStream) flush() {
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+}
+
+// heartbeat writes comments until the stream is closed or the client disconnects
+func (s *EventStream) heartbeat(period time.Duration) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			if s.write([]byte(": heartbeat\n\n")) != nil {
+				return
+			}
+		}
+	}
+}
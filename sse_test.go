@@ -0,0 +1,108 @@
+package router
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestControlSSE(t *testing.T) {
+	r := New()
+	r.GET("/events", func(c *Control) {
+		events := c.SSE()
+		events.Send("", "", "hello")
+		events.Send("update", "2", map[string]int{"count": 2})
+		events.Send("multi", "3\n", "line one\nline two")
+		events.Retry(3 * time.Second)
+		c.Set(Param{Key: "last", Value: events.LastEventID()})
+	})
+	req, _ := http.NewRequest("GET", "/events", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Last-Event-ID", "1")
+	trw := httptest.NewRecorder()
+	r.ServeHTTP(trw, req)
+	expected := "data: hello\n\n" +
+		"event: update\nid: 2\ndata: {\"count\":2}\n\n" +
+		"event: multi\nid: 3\ndata: line one\ndata: line two\n\n" +
+		"retry: 3000\n\n"
+	if trw.Body.String() != expected {
+		t.Error("Expected", expected, "got", trw.Body.String())
+	}
+	if trw.Header().Get("Content-type") != MIMEEventStream || trw.Header().Get("Content-Encoding") != "" {
+		t.Error("Expected not compressed event stream, got", trw.Header())
+	}
+	if !trw.Flushed {
+		t.Error("Expected flushed response")
+	}
+}
+
+func TestControlSSEHeartbeat(t *testing.T) {
+	heartbeat := SSEHeartbeat
+	SSEHeartbeat = 5 * time.Millisecond
+	defer func() { SSEHeartbeat = heartbeat }()
+
+	r := New()
+	last := make(chan string, 1)
+	done := make(chan error, 1)
+	r.GET("/events", func(c *Control) {
+		events := c.SSE()
+		last <- events.LastEventID()
+		for id := 1; ; id++ {
+			select {
+			case <-events.Done():
+				done <- events.Send("tick", "", id)
+				return
+			case <-time.After(20 * time.Millisecond):
+				if err := events.Send("tick", "", id); err != nil {
+					done <- err
+					return
+				}
+			}
+		}
+	})
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequest("GET", server.URL+"/events", nil)
+	req.Header.Set("Last-Event-ID", "42")
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := <-last; id != "42" {
+		t.Error("Expected", "42", "got", id)
+	}
+	reader := bufio.NewReader(resp.Body)
+	heartbeats, events := 0, 0
+	for events < 2 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case strings.HasPrefix(line, ": heartbeat"):
+			heartbeats++
+		case strings.HasPrefix(line, "event: tick"):
+			events++
+		}
+	}
+	if heartbeats == 0 {
+		t.Error("Expected heartbeat comments")
+	}
+	// the handler stops when the client disconnects
+	cancel()
+	resp.Body.Close()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Expected error after disconnect")
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected the handler to stop after disconnect")
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright 2015 Igor Dolzhikov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"time"
+)
+
+// MIMENDJSON - "Content-type" for newline delimited JSON
+const MIMENDJSON = "application/x-ndjson"
+
+// StreamFlushInterval is a period of flushing of streamed responses
+var StreamFlushInterval = 100 * time.Millisecond
+
+// errStreamSource is returned if the source of a stream is not supported
+var errStreamSource = errors.New("router: stream source must be a channel, iter.Seq or slice")
+
+// streamPlaceholder marks a place of streamed data in the metadata envelope
+const streamPlaceholder = `"\u0000stream\u0000"`
+
+// StreamJSON writes items from the source into the response as a compact JSON array
+// without holding all of them in memory. The source is a channel, iter.Seq or slice.
+// If metadata is used, the array is written as data of the envelope.
+// Streaming stops when the source is exhausted or the request is cancelled,
+// in the last case the context error is returned.
+func (c *Control) StreamJSON(source interface{}) error {
+	c.checkReleased()
+	if !isStreamSource(source) {
+		return errStreamSource
+	}
+	var prefix, suffix []byte
+	if c.useMetaData {
+		content, err := json.Marshal(c.metaData(json.RawMessage(streamPlaceholder)))
+		if err != nil {
+			return err
+		}
+		idx := bytes.Index(content, []byte(streamPlaceholder))
+		prefix, suffix = content[:idx], content[idx+len(streamPlaceholder):]
+	}
+	stream := c.stream(MIMEJSON)
+	defer stream.close()
+	stream.write(append(prefix, '['))
+	first := true
+	err := each(c.Request.Context(), source, func(item interface{}) error {
+		content, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		if !first {
+			stream.write([]byte{','})
+		}
+		first = false
+		return stream.write(content)
+	})
+	if err != nil {
+		return err
+	}
+	return stream.write(append([]byte{']'}, suffix...))
+}
+
+// StreamNDJSON writes items from the source into the response as newline delimited JSON,
+// one item per line. The source is a channel, iter.Seq or slice. Metadata is not used.
+// Streaming stops when the source is exhausted or the request is cancelled,
+// in the last case the context error is returned.
+func (c *Control) StreamNDJSON(source interface{}) error {
+	c.checkReleased()
+	if !isStreamSource(source) {
+		return errStreamSource
+	}
+	stream := c.stream(MIMENDJSON)
+	defer stream.close()
+	return each(c.Request.Context(), source, func(item interface{}) error {
+		content, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		return stream.write(append(content, '\n'))
+	})
+}
+
+// streamWriter writes a response with periodic flushing
+type streamWriter struct {
+	w io.Writer
+	// compressor and its encoding if the response is compressed
+	cw       compressor
+	encoding string
+	cmp      *Compression
+	flusher  http.Flusher
+	flushed  time.Time
+	err      error
+}
+
+// stream writes headers and returns a writer of the response body
+func (c *Control) stream(contentType string) *streamWriter {
+	header := c.Writer.Header()
+	header.Set("Content-type", contentType)
+	header.Set("X-Content-Type-Options", "nosniff")
+	s := &streamWriter{w: c.Writer, flushed: time.Now()}
+	s.flusher, _ = c.Writer.(http.Flusher)
+	if encoding := c.contentEncoding(-1); encoding != "" {
+		header.Set("Content-Encoding", encoding)
+		header.Del("Content-Length")
+		s.cmp, s.encoding = c.compression(), encoding
+		s.cw = s.cmp.writer(encoding, c.Writer)
+		s.w = s.cw
+	}
+	if c.code > 0 {
+		c.Writer.WriteHeader(c.code)
+	}
+	return s
+}
+
+func (s *streamWriter) write(data []byte) error {
+	if s.err != nil {
+		return s.err
+	}
+	if _, s.err = s.w.Write(data); s.err != nil {
+		return s.err
+	}
+	if time.Since(s.flushed) >= StreamFlushInterval {
+		s.flush()
+	}
+	return s.err
+}
+
+func (s *streamWriter) flush() {
+	if s.cw != nil && s.err == nil {
+		s.err = s.cw.Flush()
+	}
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+	s.flushed = time.Now()
+}
+
+func (s *streamWriter) close() {
+	if s.cw != nil {
+		s.cw.Close()
+		s.cmp.release(s.encoding, s.cw)
+	}
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+}
+
+// isStreamSource checks whether the source is a receiving channel, iter.Seq or slice
+func isStreamSource(source interface{}) bool {
+	value := reflect.ValueOf(source)
+	switch value.Kind() {
+	case reflect.Chan:
+		return value.Type().ChanDir()&reflect.RecvDir != 0
+	case reflect.Func:
+		// iter.Seq[T] is func(yield func(T) bool)
+		t := value.Type()
+		if t.NumIn() != 1 || t.NumOut() != 0 {
+			return false
+		}
+		yield := t.In(0)
+		return yield.Kind() == reflect.Func && yield.NumIn() == 1 && yield.NumOut() == 1 && yield.Out(0).Kind() == reflect.Bool
+	case reflect.Slice, reflect.Array:
+		return true
+	}
+	return false
+}
+
+// each calls fn for every item of the source until the context is cancelled
+func each(ctx context.Context, source interface{}, fn func(item interface{}) error) error {
+	if !isStreamSource(source) {
+		return errStreamSource
+	}
+	value := reflect.ValueOf(source)
+	switch value.Kind() {
+	case reflect.Chan:
+		cases := []reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+			{Dir: reflect.SelectRecv, Chan: value},
+		}
+		for {
+			chosen, item, ok := reflect.Select(cases)
+			if chosen == 0 {
+				return ctx.Err()
+			}
+			if !ok {
+				return nil
+			}
+			if err := fn(item.Interface()); err != nil {
+				return err
+			}
+		}
+	case reflect.Func:
+		var err error
+		yield := reflect.MakeFunc(value.Type().In(0), func(args []reflect.Value) []reflect.Value {
+			if err = ctx.Err(); err == nil {
+				err = fn(args[0].Interface())
+			}
+			return []reflect.Value{reflect.ValueOf(err == nil)}
+		})
+		value.Call([]reflect.Value{yield})
+		return err
+	default:
+		for idx := 0; idx < value.Len(); idx++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(value.Index(idx).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
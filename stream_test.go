@@ -0,0 +1,114 @@
+package router
+
+import (
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestControlStreamJSON(t *testing.T) {
+	r := New()
+	r.GET("/seq", func(c *Control) {
+		if err := c.StreamJSON(slices.Values([]string{"a", "b", "c"})); err != nil {
+			t.Error(err)
+		}
+	})
+	r.GET("/chan", func(c *Control) {
+		items := make(chan map[string]int)
+		go func() {
+			for idx := 1; idx <= 2; idx++ {
+				items <- map[string]int{"id": idx}
+			}
+			close(items)
+		}()
+		if err := c.APIVersion("2.0").StreamJSON(items); err != nil {
+			t.Error(err)
+		}
+	})
+	r.GET("/ndjson", func(c *Control) {
+		if err := c.StreamNDJSON([]int{1, 2, 3}); err != nil {
+			t.Error(err)
+		}
+	})
+	r.GET("/invalid", func(c *Control) {
+		if err := c.StreamJSON(17); err == nil {
+			t.Error("Expected error for unsupported source")
+		}
+	})
+
+	trw := testRequest(r, "GET", "/seq", nil)
+	if trw.Body.String() != `["a","b","c"]` || trw.Header().Get("Content-type") != MIMEJSON {
+		t.Error("Expected", `["a","b","c"]`, "got", trw.Body.String())
+	}
+	if !trw.Flushed {
+		t.Error("Expected flushed response")
+	}
+	if trw = testRequest(r, "GET", "/chan", nil); trw.Body.String() != `{"apiVersion":"2.0","data":[{"id":1},{"id":2}]}` {
+		t.Error("Expected", `{"apiVersion":"2.0","data":[{"id":1},{"id":2}]}`, "got", trw.Body.String())
+	}
+	trw = testRequest(r, "GET", "/ndjson", nil)
+	if trw.Body.String() != "1\n2\n3\n" || trw.Header().Get("Content-type") != MIMENDJSON {
+		t.Error("Expected", "1\n2\n3\n", "got", trw.Body.String())
+	}
+	if trw = testRequest(r, "GET", "/invalid", nil); trw.Body.Len() != 0 {
+		t.Error("Expected empty body, got", trw.Body.String())
+	}
+
+	trw = testRequest(r, "GET", "/ndjson", map[string]string{"Accept-Encoding": "gzip"})
+	gz, err := gzip.NewReader(trw.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := ioutil.ReadAll(gz); string(content) != "1\n2\n3\n" {
+		t.Error("Expected", "1\n2\n3\n", "got", string(content))
+	}
+}
+
+func TestControlStreamCancel(t *testing.T) {
+	r := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	r.GET("/events", func(c *Control) {
+		items := make(chan int)
+		go func() {
+			items <- 1
+			cancel()
+		}()
+		done <- c.StreamNDJSON(items)
+	})
+	req, _ := http.NewRequest("GET", "/events", nil)
+	trw := httptest.NewRecorder()
+	r.ServeHTTP(trw, req.WithContext(ctx))
+	if err := <-done; err != context.Canceled {
+		t.Error("Expected", context.Canceled, "got", err)
+	}
+	if trw.Body.String() != "1\n" {
+		t.Error("Expected", "1\n", "got", trw.Body.String())
+	}
+
+	// iterators are stopped too
+	ctx, cancel = context.WithCancel(context.Background())
+	count := 0
+	r.GET("/numbers", func(c *Control) {
+		done <- c.StreamJSON(func(yield func(int) bool) {
+			for idx := 0; idx < 100; idx++ {
+				if idx == 2 {
+					cancel()
+				}
+				count++
+				if !yield(idx) {
+					return
+				}
+			}
+		})
+	})
+	req, _ = http.NewRequest("GET", "/numbers", nil)
+	r.ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+	if err := <-done; err != context.Canceled || count != 3 {
+		t.Error("Expected", context.Canceled, 3, "got", err, count)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2015 Igor Dolzhikov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"sync"
+)
+
+// MIMEHTML - "Content-type" for HTML
+const MIMEHTML = "text/html; charset=utf-8"
+
+// Templates is a registry of HTML templates which are rendered by Control.HTML.
+// Every page is parsed with all layouts and partials, so a page may define blocks
+// and execute a layout, e.g.
+//
+//	{{define "content"}}<h1>{{.Title}}</h1>{{end}}
+//	{{template "layouts/base.html" .}}
+//
+// Pages and layouts are named by their paths in the file system.
+// In development mode templates are parsed again on each request.
+type Templates struct {
+	// FS contains files of templates
+	FS fs.FS
+
+	// Layouts are patterns of layouts and partials which are shared by pages
+	Layouts []string
+
+	// Pages are patterns of pages which are rendered by name
+	Pages []string
+
+	// Funcs are added to the template functions, by default "url" builds
+	// the path of the named route from key/value pairs, e.g. {{url "user" "id" .ID}}
+	Funcs template.FuncMap
+
+	mu    sync.RWMutex
+	pages map[string]*template.Template
+}
+
+// LoadTemplates parses the templates and registers them in the router
+func (r *Router) LoadTemplates(ts *Templates) error {
+	pages, err := ts.parse(r)
+	if err != nil {
+		return err
+	}
+	ts.mu.Lock()
+	ts.pages = pages
+	ts.mu.Unlock()
+	r.mu.Lock()
+	r.templates = ts
+	r.mu.Unlock()
+	return nil
+}
+
+// HTML renders the page with the data by templates of the router
+func (c *Control) HTML(code int, name string, data interface{}) {
+	c.checkReleased()
+	var ts *Templates
+	if c.router != nil {
+		c.router.mu.RLock()
+		ts = c.router.templates
+		c.router.mu.RUnlock()
+	}
+	if ts == nil {
+		c.fail(OpRender, errors.New("router: templates are not loaded"))
+		return
+	}
+	page, err := ts.lookup(c.router, name)
+	if err != nil {
+		c.fail(OpRender, err)
+		return
+	}
+	var buf bytes.Buffer
+	if err := page.ExecuteTemplate(&buf, name, data); err != nil {
+		c.fail(OpRender, err)
+		return
+	}
+	c.Code(code)
+	c.Writer.Header().Set("Content-type", MIMEHTML)
+	c.write(buf.Bytes())
+}
+
+// lookup returns the template set of the page, it is parsed again in development mode
+func (ts *Templates) lookup(r *Router, name string) (*template.Template, error) {
+	var pages map[string]*template.Template
+	if r.Development {
+		var err error
+		if pages, err = ts.parse(r); err != nil {
+			return nil, err
+		}
+	} else {
+		ts.mu.RLock()
+		pages = ts.pages
+		ts.mu.RUnlock()
+	}
+	page, ok := pages[name]
+	if !ok {
+		return nil, fmt.Errorf("router: template %q is not found", name)
+	}
+	return page, nil
+}
+
+// parse returns template sets of pages with layouts
+func (ts *Templates) parse(r *Router) (map[string]*template.Template, error) {
+	base := template.New("").Funcs(template.FuncMap{"url": templateURL(r)}).Funcs(ts.Funcs)
+	layouts, err := globFiles(ts.FS, ts.Layouts)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range layouts {
+		if err := parseFile(base, ts.FS, name); err != nil {
+			return nil, err
+		}
+	}
+	names, err := globFiles(ts.FS, ts.Pages)
+	if err != nil {
+		return nil, err
+	}
+	pages := make(map[string]*template.Template, len(names))
+	for _, name := range names {
+		page, err := base.Clone()
+		if err != nil {
+			return nil, err
+		}
+		if err := parseFile(page, ts.FS, name); err != nil {
+			return nil, err
+		}
+		pages[name] = page
+	}
+	return pages, nil
+}
+
+// globFiles returns names of files which match the patterns
+func globFiles(fsys fs.FS, patterns []string) ([]string, error) {
+	var names []string
+	for _, pattern := range patterns {
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, matches...)
+	}
+	return names, nil
+}
+
+// parseFile adds the template of the file to the set with the name of the file
+func parseFile(set *template.Template, fsys fs.FS, name string) error {
+	content, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return err
+	}
+	_, err = set.New(name).Parse(string(content))
+	return err
+}
+
+// templateURL returns "url" template function which builds paths of named routes
+func templateURL(r *Router) func(name string, pairs ...interface{}) (string, error) {
+	return func(name string, pairs ...interface{}) (string, error) {
+		if len(pairs)%2 != 0 {
+			return "", fmt.Errorf("router: parameters of route %q must be key/value pairs", name)
+		}
+		params := make([]Param, 0, len(pairs)/2)
+		for idx := 0; idx < len(pairs); idx += 2 {
+			params = append(params, Param{Key: fmt.Sprint(pairs[idx]), Value: fmt.Sprint(pairs[idx+1])})
+		}
+		return r.URL(name, params...)
+	}
+}
@@ -0,0 +1,108 @@
+package router
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func testTemplates() fstest.MapFS {
+	return fstest.MapFS{
+		"layouts/base.html": {Data: []byte(`<html><title>{{block "title" .}}Admin{{end}}</title>` +
+			`<body>{{template "content" .}}{{template "partials/footer.html" .}}</body></html>`)},
+		"partials/footer.html": {Data: []byte(`<footer>{{upper "footer"}}</footer>`)},
+		"pages/user.html": {Data: []byte(`{{define "title"}}User{{end}}` +
+			`{{define "content"}}<a href="{{url "user" "id" .ID}}">{{.Name}}</a>{{end}}` +
+			`{{template "layouts/base.html" .}}`)},
+		"pages/email.html":  {Data: []byte(`Hello, {{.Name}}`)},
+		"pages/broken.html": {Data: []byte(`{{template "missing" .}}`)},
+	}
+}
+
+func TestControlHTML(t *testing.T) {
+	r := New()
+	r.GET("/users/:id", func(c *Control) {
+		c.HTML(http.StatusOK, "pages/user.html", map[string]interface{}{"ID": 7, "Name": "<Bob>"})
+	}).Name("user")
+	r.GET("/email", func(c *Control) {
+		c.HTML(http.StatusAccepted, "pages/email.html", map[string]string{"Name": "Alice"})
+	})
+	r.GET("/invalid", func(c *Control) {
+		c.HTML(42, "pages/email.html", map[string]string{"Name": "Alice"})
+	})
+	r.GET("/broken", func(c *Control) {
+		c.HTML(http.StatusOK, "pages/broken.html", nil)
+	})
+	r.GET("/unknown", func(c *Control) {
+		c.HTML(http.StatusOK, "pages/unknown.html", nil)
+	})
+
+	trw := testRequest(r, "GET", "/email", nil)
+	if trw.Code != http.StatusInternalServerError {
+		t.Error("Expected", http.StatusInternalServerError, "without templates, got", trw.Code)
+	}
+
+	err := r.LoadTemplates(&Templates{
+		FS:      testTemplates(),
+		Layouts: []string{"layouts/*.html", "partials/*.html"},
+		Pages:   []string{"pages/*.html"},
+		Funcs:   template.FuncMap{"upper": strings.ToUpper},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trw = testRequest(r, "GET", "/users/7", nil)
+	expected := `<html><title>User</title><body><a href="/users/7">&lt;Bob&gt;</a><footer>FOOTER</footer></body></html>`
+	if trw.Code != http.StatusOK || trw.Body.String() != expected || trw.Header().Get("Content-type") != MIMEHTML {
+		t.Error("Expected", expected, "got", trw.Code, trw.Body.String(), trw.Header().Get("Content-type"))
+	}
+	if trw = testRequest(r, "GET", "/email", nil); trw.Code != http.StatusAccepted || trw.Body.String() != "Hello, Alice" {
+		t.Error("Expected", "Hello, Alice", "got", trw.Code, trw.Body.String())
+	}
+	if trw = testRequest(r, "GET", "/invalid", nil); trw.Code != http.StatusOK || trw.Body.String() != "Hello, Alice" {
+		t.Error("Expected", http.StatusOK, "for invalid code, got", trw.Code)
+	}
+
+	var errs []error
+	r.ErrorHandler = func(c *Control, err error) {
+		errs = append(errs, err)
+		c.Writer.WriteHeader(http.StatusInternalServerError)
+	}
+	testRequest(r, "GET", "/broken", nil)
+	testRequest(r, "GET", "/unknown", nil)
+	var responseErr *ResponseError
+	if len(errs) != 2 || !errors.As(errs[0], &responseErr) || responseErr.Op != OpRender {
+		t.Error("Expected template errors, got", errs)
+	}
+}
+
+func TestTemplatesDevelopment(t *testing.T) {
+	fsys := testTemplates()
+	r := New()
+	r.GET("/email", func(c *Control) {
+		c.HTML(http.StatusOK, "pages/email.html", map[string]string{"Name": "Alice"})
+	})
+	err := r.LoadTemplates(&Templates{FS: fsys, Pages: []string{"pages/email.html"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys["pages/email.html"].Data = []byte(`Hi, {{.Name}}`)
+	if trw := testRequest(r, "GET", "/email", nil); trw.Body.String() != "Hello, Alice" {
+		t.Error("Expected cached template, got", trw.Body.String())
+	}
+	r.Development = true
+	if trw := testRequest(r, "GET", "/email", nil); trw.Body.String() != "Hi, Alice" {
+		t.Error("Expected reloaded template, got", trw.Body.String())
+	}
+
+	if err := r.LoadTemplates(&Templates{FS: fsys, Pages: []string{"pages/broken.html"}}); err != nil {
+		t.Fatal("Expected missing templates to fail on execution, got", err)
+	}
+	fsys["pages/bad.html"] = &fstest.MapFile{Data: []byte(`{{if}}`)}
+	if err := r.LoadTemplates(&Templates{FS: fsys, Pages: []string{"pages/*.html"}}); err == nil {
+		t.Error("Expected parse error")
+	}
+}
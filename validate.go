@@ -0,0 +1,349 @@
+// Copyright 2015 Igor Dolzhikov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Validator checks the value of a field against the parameter of the rule,
+// e.g. "3" for the rule "min=3". Pointers are dereferenced before the check.
+type Validator func(value reflect.Value, param string) error
+
+var validators = struct {
+	sync.RWMutex
+	rules map[string]Validator
+}{
+	rules: map[string]Validator{
+		"required": validateRequired,
+		"min":      validateMin,
+		"max":      validateMax,
+		"len":      validateLen,
+		"regex":    validateRegex,
+		"oneof":    validateOneOf,
+		"email":    validateEmail,
+	},
+}
+
+// regexps contains compiled patterns of the "regex" rule
+var regexps sync.Map
+
+// RegisterValidator adds a validation rule which may be used in "validate" tags
+// by its name. Built-in rules may be replaced in the same way.
+func RegisterValidator(name string, fn Validator) {
+	validators.Lock()
+	validators.rules[name] = fn
+	validators.Unlock()
+}
+
+// Validate checks fields of the structure pointed by v by rules of "validate" tags:
+//
+//	type User struct {
+//		Name  string   `json:"name" validate:"required,min=2,max=64"`
+//		Email string   `json:"email" validate:"required,email"`
+//		Role  string   `json:"role" validate:"oneof=admin user"`
+//		Tags  []string `json:"tags" validate:"max=5,dive,len=3"`
+//		Code  string   `query:"code" validate:"regex=^[a-z]+$"`
+//	}
+//
+// Rules are separated by commas, the "regex" rule must be the last one.
+// Rules after "dive" are applied to elements of slices and maps.
+// Empty fields are checked only if they are required.
+// Nested structures are validated recursively.
+// Returned error is BindErrors with one error per invalid field.
+func Validate(v interface{}) error {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return errors.New("router: Validate requires a structure")
+	}
+	if errs := validateStruct(value, "", nil); len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// validateStruct checks fields of the structure, prefix is a location of the structure in the body
+func validateStruct(value reflect.Value, prefix string, errs BindErrors) BindErrors {
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		location, name := fieldLocation(field, prefix)
+		if name == "-" {
+			continue
+		}
+		fv := value.Field(i)
+		count := len(errs)
+		if tag := field.Tag.Get("validate"); tag != "" {
+			errs = validateValue(fv, parseRules(tag), location, name, errs)
+		}
+		if len(errs) > count {
+			continue
+		}
+		if field.Anonymous && location == BindBody && field.Tag.Get("json") == "" {
+			name = prefix
+		}
+		errs = validateNested(fv, name, errs)
+	}
+
+	return errs
+}
+
+// fieldLocation returns location and name of the field, fields without
+// source tags are located in the body by their JSON names
+func fieldLocation(field reflect.StructField, prefix string) (string, string) {
+	for _, location := range bindTags {
+		if name, ok := field.Tag.Lookup(location); ok && name != "-" {
+			return location, name
+		}
+	}
+	name := field.Name
+	if tag, ok := field.Tag.Lookup("json"); ok {
+		if tag = strings.Split(tag, ",")[0]; tag != "" {
+			name = tag
+		}
+	}
+	if prefix != "" && name != "-" {
+		name = prefix + "." + name
+	}
+
+	return BindBody, name
+}
+
+// validateNested validates structures which are contained in the value
+func validateNested(value reflect.Value, name string, errs BindErrors) BindErrors {
+	value = indirect(value)
+	switch value.Kind() {
+	case reflect.Struct:
+		if value.Type() != timeType && !reflect.PtrTo(value.Type()).Implements(textUnmarshalerType) {
+			errs = validateStruct(value, name, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for idx := 0; idx < value.Len(); idx++ {
+			errs = validateNested(value.Index(idx), fmt.Sprintf("%s[%d]", name, idx), errs)
+		}
+	}
+
+	return errs
+}
+
+// validateValue applies the rules to the value and stops on the first failed rule
+func validateValue(value reflect.Value, rules []string, location, name string, errs BindErrors) BindErrors {
+	if value.IsZero() && !hasRule(rules, "required") {
+		return errs
+	}
+	for idx, rule := range rules {
+		if rule == "dive" {
+			return validateElements(indirect(value), rules[idx+1:], location, name, errs)
+		}
+		ruleName, param := rule, ""
+		if pos := strings.IndexByte(rule, '='); pos >= 0 {
+			ruleName, param = rule[:pos], rule[pos+1:]
+		}
+		validators.RLock()
+		fn := validators.rules[ruleName]
+		validators.RUnlock()
+		var err error
+		if fn == nil {
+			err = fmt.Errorf("unknown validation rule %q", ruleName)
+		} else {
+			err = fn(indirect(value), param)
+		}
+		if err != nil {
+			return append(errs, &BindError{Field: name, Location: location, Rule: ruleName, Err: err})
+		}
+	}
+
+	return errs
+}
+
+// validateElements applies the rules to elements of slices, arrays and maps
+func validateElements(value reflect.Value, rules []string, location, name string, errs BindErrors) BindErrors {
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for idx := 0; idx < value.Len(); idx++ {
+			errs = validateValue(value.Index(idx), rules, location, fmt.Sprintf("%s[%d]", name, idx), errs)
+		}
+	case reflect.Map:
+		keys := value.MapKeys()
+		names := make([]string, len(keys))
+		order := make([]int, len(keys))
+		for idx, key := range keys {
+			names[idx], order[idx] = fmt.Sprint(key.Interface()), idx
+		}
+		// errors are reported in order of keys
+		sort.Slice(order, func(i, j int) bool { return names[order[i]] < names[order[j]] })
+		for _, idx := range order {
+			errs = validateValue(value.MapIndex(keys[idx]), rules, location, name+"."+names[idx], errs)
+		}
+	}
+
+	return errs
+}
+
+// hasRule checks whether the rule is present before "dive"
+func hasRule(rules []string, name string) bool {
+	for _, rule := range rules {
+		if rule == "dive" {
+			break
+		}
+		if rule == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRules splits the tag into rules, the "regex" rule takes the rest of the tag
+func parseRules(tag string) []string {
+	var rules []string
+	for tag != "" {
+		if strings.HasPrefix(tag, "regex=") {
+			return append(rules, tag)
+		}
+		rule := tag
+		if pos := strings.IndexByte(tag, ','); pos >= 0 {
+			rule, tag = tag[:pos], tag[pos+1:]
+		} else {
+			tag = ""
+		}
+		if rule != "" {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules
+}
+
+// indirect dereferences non-nil pointers
+func indirect(value reflect.Value) reflect.Value {
+	for value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+	return value
+}
+
+func validateRequired(value reflect.Value, param string) error {
+	if !value.IsValid() || value.IsZero() || (value.Kind() == reflect.Slice || value.Kind() == reflect.Map) && value.Len() == 0 {
+		return errors.New("is required")
+	}
+	return nil
+}
+
+func validateMin(value reflect.Value, param string) error {
+	n, isLength, err := measure(value, param)
+	if err != nil {
+		return err
+	}
+	limit, _ := strconv.ParseFloat(param, 64)
+	if n < limit {
+		if isLength {
+			return fmt.Errorf("length must be at least %s", param)
+		}
+		return fmt.Errorf("must be at least %s", param)
+	}
+	return nil
+}
+
+func validateMax(value reflect.Value, param string) error {
+	n, isLength, err := measure(value, param)
+	if err != nil {
+		return err
+	}
+	limit, _ := strconv.ParseFloat(param, 64)
+	if n > limit {
+		if isLength {
+			return fmt.Errorf("length must be at most %s", param)
+		}
+		return fmt.Errorf("must be at most %s", param)
+	}
+	return nil
+}
+
+func validateLen(value reflect.Value, param string) error {
+	n, _, err := measure(value, param)
+	if err != nil {
+		return err
+	}
+	limit, _ := strconv.ParseFloat(param, 64)
+	if n != limit {
+		return fmt.Errorf("length must be %s", param)
+	}
+	return nil
+}
+
+// measure returns a number or a length of the value and reports whether it is a length
+func measure(value reflect.Value, param string) (float64, bool, error) {
+	if _, err := strconv.ParseFloat(param, 64); err != nil {
+		return 0, false, fmt.Errorf("invalid rule parameter %q", param)
+	}
+	switch value.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(value.String())), true, nil
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(value.Len()), true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), false, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), false, nil
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), false, nil
+	}
+	return 0, false, fmt.Errorf("unsupported type %s", value.Type())
+}
+
+func validateRegex(value reflect.Value, param string) error {
+	if value.Kind() != reflect.String {
+		return fmt.Errorf("unsupported type %s", value.Type())
+	}
+	re, ok := regexps.Load(param)
+	if !ok {
+		compiled, err := regexp.Compile(param)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q", param)
+		}
+		re, _ = regexps.LoadOrStore(param, compiled)
+	}
+	if !re.(*regexp.Regexp).MatchString(value.String()) {
+		return fmt.Errorf("must match %s", param)
+	}
+	return nil
+}
+
+func validateOneOf(value reflect.Value, param string) error {
+	str := fmt.Sprint(value.Interface())
+	for _, option := range strings.Fields(param) {
+		if str == option {
+			return nil
+		}
+	}
+	return fmt.Errorf("must be one of %s", strings.Join(strings.Fields(param), ", "))
+}
+
+func validateEmail(value reflect.Value, param string) error {
+	if value.Kind() != reflect.String {
+		return fmt.Errorf("unsupported type %s", value.Type())
+	}
+	address, err := mail.ParseAddress(value.String())
+	if err != nil || address.Address != value.String() {
+		return errors.New("must be a valid email address")
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testAddress struct {
+	City string `json:"city" validate:"required"`
+}
+
+type testUserForm struct {
+	Code  string            `query:"code" validate:"regex=^[a-z]+$"`
+	User  testUserFields    `json:"user"`
+	Tags  []string          `json:"tags" validate:"max=3,dive,len=2"`
+	Addrs []testAddress     `json:"addresses"`
+	Attrs map[string]string `json:"attrs" validate:"dive,oneof=on off"`
+	Age   *int              `json:"age" validate:"min=18,max=99"`
+}
+
+type testUserFields struct {
+	Name  string `json:"name" validate:"required,min=2"`
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"oneof=admin user"`
+	Note  string `json:"note" validate:"even"`
+}
+
+func TestValidate(t *testing.T) {
+	age := 17
+	v := testUserForm{
+		Code:  "ABC",
+		User:  testUserFields{Name: "J", Email: "not an email", Role: "guest"},
+		Tags:  []string{"ok", "bad"},
+		Addrs: []testAddress{{City: "Moscow"}, {}},
+		Attrs: map[string]string{"b": "maybe", "a": "on"},
+		Age:   &age,
+	}
+	errs, ok := Validate(&v).(BindErrors)
+	if !ok {
+		t.Fatal("Expected BindErrors")
+	}
+	expected := []string{
+		"query code regex", "body user.name min", "body user.email email", "body user.role oneof",
+		"body tags[1] len", "body addresses[1].city required", "body attrs.b oneof", "body age min",
+	}
+	if len(errs) != len(expected) {
+		t.Fatal("Expected", len(expected), "errors, got", errs)
+	}
+	for idx, err := range errs {
+		if got := err.Location + " " + err.Field + " " + err.Rule; got != expected[idx] {
+			t.Error("Expected", expected[idx], "got", got)
+		}
+	}
+	if reasons := errs.Errors(); reasons[5].Reason != "required" || reasons[0].Reason != "invalid" {
+		t.Error("Unexpected reasons", reasons[5].Reason, reasons[0].Reason)
+	}
+
+	// empty optional values are not checked
+	if err := Validate(testUserForm{User: testUserFields{Name: "John", Email: "john@example.com"}}); err != nil {
+		t.Error("Expected no errors, got", err)
+	}
+}
+
+func TestRegisterValidator(t *testing.T) {
+	RegisterValidator("even", func(value reflect.Value, param string) error {
+		if len(value.String())%2 != 0 {
+			return errors.New("must have even length")
+		}
+		return nil
+	})
+	v := testUserFields{Name: "John", Email: "john@example.com", Note: "odd"}
+	errs, ok := Validate(v).(BindErrors)
+	if !ok || len(errs) != 1 || errs[0].Field != "note" || errs[0].Err.Error() != "must have even length" {
+		t.Error("Expected custom rule error, got", errs)
+	}
+}
+
+func TestControlBindValidation(t *testing.T) {
+	r := New()
+	r.POST("/users", func(c *Control) {
+		var v testUserForm
+		if err := c.Bind(&v); err != nil {
+			c.CompactJSON(true).Body(nil)
+			return
+		}
+		c.Body(v.User.Name)
+	})
+	req, _ := http.NewRequest("POST", "/users", strings.NewReader(`{"user": {"name": "John", "email": "john@"}}`))
+	req.Header.Set("Content-Type", MIMEJSON)
+	trw := httptest.NewRecorder()
+	r.ServeHTTP(trw, req)
+	if trw.Code != http.StatusBadRequest {
+		t.Error("Expected", http.StatusBadRequest, "got", trw.Code)
+	}
+	var body struct {
+		Error ErrorHeader `json:"error"`
+	}
+	if err := json.Unmarshal(trw.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Error.Code != http.StatusBadRequest || len(body.Error.Errors) != 1 {
+		t.Fatal("Unexpected error", body.Error)
+	}
+	if e := body.Error.Errors[0]; e.Location != "user.email" || e.LocationType != "body" || e.Reason != "invalid" {
+		t.Error("Expected", "user.email body invalid", "got", e.Location, e.LocationType, e.Reason)
+	}
+}
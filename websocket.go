@@ -0,0 +1,688 @@
+// Copyright 2015 Igor Dolzhikov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Types of WebSocket messages (frame opcodes)
+const (
+	continuationFrame = 0
+	TextMessage       = 1
+	BinaryMessage     = 2
+	CloseMessage      = 8
+	PingMessage       = 9
+	PongMessage       = 10
+)
+
+// Close codes of WebSocket connections (RFC 6455, section 7.4.1)
+const (
+	CloseNormalClosure       = 1000
+	CloseGoingAway           = 1001
+	CloseProtocolError       = 1002
+	CloseUnsupportedData     = 1003
+	CloseNoStatusReceived    = 1005
+	CloseAbnormalClosure     = 1006
+	CloseInvalidPayload      = 1007
+	ClosePolicyViolation     = 1008
+	CloseMessageTooBig       = 1009
+	CloseMandatoryExtension  = 1010
+	CloseInternalServerError = 1011
+)
+
+// websocketGUID is used to calculate Sec-WebSocket-Accept
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxControlPayload is a payload limit of control frames
+const maxControlPayload = 125
+
+// deflateTail is removed from compressed messages and added back before decompression
+const deflateTail = "\x00\x00\xff\xff"
+
+// ErrBadHandshake is returned by DialWebSocket when the server declines the upgrade
+var ErrBadHandshake = errors.New("websocket: bad handshake")
+
+// CloseError is returned by Conn.ReadMessage when the connection is closed
+// by the peer or because of a protocol violation
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return "websocket: close " + strconv.Itoa(e.Code) + " " + e.Text
+}
+
+// Upgrader defines options of WebSocket handshake
+type Upgrader struct {
+	// CheckOrigin returns true if the request is allowed. If it is not set,
+	// requests without "Origin" header and requests from the same host are allowed.
+	CheckOrigin func(req *http.Request) bool
+
+	// Subprotocols supported by the server in order of preference
+	Subprotocols []string
+
+	// ReadLimit is a maximum size of incoming messages, default is 32 MB
+	ReadLimit int64
+
+	// Compression allows permessage-deflate extension if it is offered by the client
+	Compression bool
+}
+
+// Upgrade switches the connection of the request to the WebSocket protocol using
+// options of Router.Upgrader. If the handshake fails, an HTTP error is already
+// written into the response. The Control must not be used to write a response after upgrade.
+func (c *Control) Upgrade() (*Conn, error) {
+	c.checkReleased()
+	upgrader := &Upgrader{}
+	if c.router != nil && c.router.Upgrader != nil {
+		upgrader = c.router.Upgrader
+	}
+	return upgrader.Upgrade(c.Writer, c.Request)
+}
+
+// Upgrade switches the connection to the WebSocket protocol, the writer must implement http.Hijacker
+func (u *Upgrader) Upgrade(w http.ResponseWriter, req *http.Request) (*Conn, error) {
+	fail := func(code int, message string) (*Conn, error) {
+		http.Error(w, message, code)
+		return nil, errors.New("websocket: " + message)
+	}
+	if req.Method != "GET" {
+		return fail(http.StatusMethodNotAllowed, "upgrade requires GET method")
+	}
+	if !headerHasToken(req.Header, "Connection", "upgrade") || !headerHasToken(req.Header, "Upgrade", "websocket") {
+		return fail(http.StatusBadRequest, "not a websocket handshake")
+	}
+	if req.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return fail(http.StatusUpgradeRequired, "unsupported websocket version")
+	}
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return fail(http.StatusBadRequest, "invalid Sec-WebSocket-Key")
+	}
+	checkOrigin := u.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(req) {
+		return fail(http.StatusForbidden, "origin not allowed")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return fail(http.StatusInternalServerError, "response does not support hijacking")
+	}
+
+	var response bytes.Buffer
+	response.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	response.WriteString("Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n")
+	subprotocol := u.subprotocol(req)
+	if subprotocol != "" {
+		response.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	compress := u.Compression && offersDeflate(req.Header)
+	if compress {
+		response.WriteString("Sec-WebSocket-Extensions: permessage-deflate; server_no_context_takeover; client_no_context_takeover\r\n")
+	}
+	response.WriteString("\r\n")
+
+	netConn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	netConn.SetDeadline(time.Time{})
+	if _, err := netConn.Write(response.Bytes()); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	conn := newConn(netConn, rw.Reader, true, u.ReadLimit)
+	conn.subprotocol, conn.compress = subprotocol, compress
+
+	return conn, nil
+}
+
+// subprotocol returns the first protocol requested by the client which is supported by the server
+func (u *Upgrader) subprotocol(req *http.Request) string {
+	for _, requested := range headerTokens(req.Header, "Sec-WebSocket-Protocol") {
+		for _, supported := range u.Subprotocols {
+			if requested == supported {
+				return supported
+			}
+		}
+	}
+	return ""
+}
+
+// sameOrigin allows requests without "Origin" header and requests from the same host
+func sameOrigin(req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, req.Host)
+}
+
+// offersDeflate checks whether the client offers permessage-deflate with parameters
+// which are supported, the window size of the server can not be reduced
+func offersDeflate(header http.Header) bool {
+	for _, offer := range headerTokens(header, "Sec-WebSocket-Extensions") {
+		params := strings.Split(offer, ";")
+		if strings.TrimSpace(params[0]) != "permessage-deflate" {
+			continue
+		}
+		supported := true
+		for _, param := range params[1:] {
+			if strings.HasPrefix(strings.TrimSpace(param), "server_max_window_bits") {
+				supported = false
+			}
+		}
+		if supported {
+			return true
+		}
+	}
+	return false
+}
+
+func acceptKey(key string) string {
+	hash := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// headerTokens returns comma separated values of the header
+func headerTokens(header http.Header, name string) []string {
+	var tokens []string
+	for _, value := range header[http.CanonicalHeaderKey(name)] {
+		for _, token := range strings.Split(value, ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return tokens
+}
+
+func headerHasToken(header http.Header, name, token string) bool {
+	for _, value := range headerTokens(header, name) {
+		if strings.EqualFold(value, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// DialWebSocket opens a client connection to the "ws", "wss", "http" or "https" URL,
+// it is useful to test WebSocket handlers with httptest.Server. The extension
+// permessage-deflate is used if it is offered in the header and accepted by the server.
+// The response is returned with ErrBadHandshake if the server declines the upgrade.
+func DialWebSocket(rawURL string, header http.Header) (*Conn, *http.Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	secure := u.Scheme == "wss" || u.Scheme == "https"
+	u.Scheme = "http"
+	if secure {
+		u.Scheme = "https"
+	}
+	host := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if secure {
+			port = "443"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+	var netConn net.Conn
+	if secure {
+		netConn, err = tls.Dial("tcp", host, &tls.Config{ServerName: u.Hostname()})
+	} else {
+		netConn, err = net.Dial("tcp", host)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	key := base64.StdEncoding.EncodeToString(nonce)
+	req := &http.Request{Method: "GET", URL: u, Host: u.Host, Header: make(http.Header)}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(netConn); err != nil {
+		netConn.Close()
+		return nil, nil, err
+	}
+	br := bufio.NewReader(netConn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		netConn.Close()
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		netConn.Close()
+		return nil, resp, ErrBadHandshake
+	}
+	conn := newConn(netConn, br, false, 0)
+	conn.subprotocol = resp.Header.Get("Sec-WebSocket-Protocol")
+	conn.compress = offersDeflate(resp.Header)
+
+	return conn, resp, nil
+}
+
+// Conn is a WebSocket connection. It supports one concurrent reader and one
+// concurrent writer of messages, control frames may be written concurrently.
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	// server connections read masked frames and write unmasked ones
+	server      bool
+	subprotocol string
+	compress    bool
+	readLimit   int64
+	readErr     error
+	pongHandler func(data []byte)
+
+	// mu serializes writes of frames
+	mu        sync.Mutex
+	closeSent bool
+}
+
+func newConn(netConn net.Conn, reader *bufio.Reader, server bool, readLimit int64) *Conn {
+	if readLimit <= 0 {
+		readLimit = defaultMaxMemory
+	}
+	return &Conn{conn: netConn, reader: reader, server: server, readLimit: readLimit}
+}
+
+// Subprotocol returns the negotiated subprotocol
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// Compressed reports whether permessage-deflate extension is used
+func (c *Conn) Compressed() bool {
+	return c.compress
+}
+
+// SetReadLimit changes a maximum size of incoming messages
+func (c *Conn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+// SetPongHandler sets a function which is called by ReadMessage for received pongs
+func (c *Conn) SetPongHandler(fn func(data []byte)) {
+	c.pongHandler = fn
+}
+
+// SetReadDeadline sets a deadline of reading from the connection
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets a deadline of writing into the connection
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// ReadMessage returns the next text or binary message, fragmented messages are joined.
+// Pings are answered automatically. When the connection is closed, *CloseError is returned.
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+	messageType, compressed := 0, false
+	var message []byte
+	for {
+		fin, rsv1, opcode, payload, err := c.readFrame(c.readLimit - int64(len(message)))
+		if err != nil {
+			return 0, nil, c.failRead(err)
+		}
+		switch opcode {
+		case PingMessage:
+			if err := c.WriteControl(PongMessage, payload); err != nil {
+				return 0, nil, c.failRead(err)
+			}
+			continue
+		case PongMessage:
+			if c.pongHandler != nil {
+				c.pongHandler(payload)
+			}
+			continue
+		case CloseMessage:
+			return 0, nil, c.failRead(closeFrameError(payload))
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, c.failRead(&CloseError{Code: CloseProtocolError, Text: "unexpected continuation frame"})
+			}
+			if rsv1 {
+				return 0, nil, c.failRead(&CloseError{Code: CloseProtocolError, Text: "unexpected RSV1 bit"})
+			}
+		default:
+			if messageType != 0 {
+				return 0, nil, c.failRead(&CloseError{Code: CloseProtocolError, Text: "expected continuation frame"})
+			}
+			if rsv1 && !c.compress {
+				return 0, nil, c.failRead(&CloseError{Code: CloseProtocolError, Text: "unexpected RSV1 bit"})
+			}
+			messageType, compressed = opcode, rsv1
+		}
+		message = append(message, payload...)
+		if !fin {
+			continue
+		}
+		if compressed {
+			if message, err = inflate(message, c.readLimit); err != nil {
+				return 0, nil, c.failRead(err)
+			}
+		}
+		if messageType == TextMessage && !utf8.Valid(message) {
+			return 0, nil, c.failRead(&CloseError{Code: CloseInvalidPayload, Text: "invalid UTF-8 in text message"})
+		}
+		return messageType, message, nil
+	}
+}
+
+// failRead stores the error of reading, close errors are answered by the close frame
+func (c *Conn) failRead(err error) error {
+	if closeErr, ok := err.(*CloseError); ok {
+		code := closeErr.Code
+		if code == CloseNoStatusReceived {
+			code = CloseNormalClosure
+		}
+		c.WriteClose(code, "")
+	}
+	c.readErr = err
+	return err
+}
+
+// closeFrameError returns an error which contains a code and a reason of the close frame
+func closeFrameError(payload []byte) error {
+	switch {
+	case len(payload) == 0:
+		return &CloseError{Code: CloseNoStatusReceived}
+	case len(payload) == 1:
+		return &CloseError{Code: CloseProtocolError, Text: "invalid close frame"}
+	}
+	code := int(binary.BigEndian.Uint16(payload))
+	if !validCloseCode(code) {
+		return &CloseError{Code: CloseProtocolError, Text: "invalid close code " + strconv.Itoa(code)}
+	}
+	if !utf8.Valid(payload[2:]) {
+		return &CloseError{Code: CloseInvalidPayload, Text: "invalid UTF-8 in close reason"}
+	}
+	return &CloseError{Code: code, Text: string(payload[2:])}
+}
+
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1014:
+		return true
+	}
+	return code >= 3000 && code <= 4999
+}
+
+// readFrame reads one frame, the limit restricts size of the data frame payload
+func (c *Conn) readFrame(limit int64) (fin, rsv1 bool, opcode int, payload []byte, err error) {
+	var head [8]byte
+	if _, err = io.ReadFull(c.reader, head[:2]); err != nil {
+		return
+	}
+	fin, rsv1, opcode = head[0]&0x80 != 0, head[0]&0x40 != 0, int(head[0]&0x0f)
+	reserved := head[0]&0x30 != 0
+	masked, length := head[1]&0x80 != 0, int64(head[1]&0x7f)
+	switch length {
+	case 126:
+		if _, err = io.ReadFull(c.reader, head[:2]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint16(head[:2]))
+	case 127:
+		if _, err = io.ReadFull(c.reader, head[:8]); err != nil {
+			return
+		}
+		if head[0]&0x80 != 0 {
+			err = &CloseError{Code: CloseProtocolError, Text: "invalid frame length"}
+			return
+		}
+		length = int64(binary.BigEndian.Uint64(head[:8]))
+	}
+	switch {
+	case reserved:
+		err = &CloseError{Code: CloseProtocolError, Text: "unexpected reserved bits"}
+	case opcode > BinaryMessage && opcode < CloseMessage, opcode > PongMessage:
+		err = &CloseError{Code: CloseProtocolError, Text: "unknown opcode " + strconv.Itoa(opcode)}
+	case masked != c.server:
+		err = &CloseError{Code: CloseProtocolError, Text: "invalid masking of frame"}
+	case opcode >= CloseMessage && (!fin || rsv1 || length > maxControlPayload):
+		err = &CloseError{Code: CloseProtocolError, Text: "invalid control frame"}
+	case opcode < CloseMessage && length > limit:
+		err = &CloseError{Code: CloseMessageTooBig, Text: "message exceeds read limit"}
+	}
+	if err != nil {
+		return
+	}
+	var key [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.reader, key[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.reader, payload); err != nil {
+		return
+	}
+	if masked {
+		maskBytes(key, payload)
+	}
+	return
+}
+
+// WriteMessage writes a text or binary message in one frame
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	switch messageType {
+	case TextMessage, BinaryMessage:
+	case CloseMessage, PingMessage, PongMessage:
+		return c.WriteControl(messageType, data)
+	default:
+		return errors.New("websocket: unknown message type " + strconv.Itoa(messageType))
+	}
+	if c.compress {
+		compressed, err := deflate(data)
+		if err != nil {
+			return err
+		}
+		return c.writeFrame(messageType, true, true, compressed)
+	}
+	return c.writeFrame(messageType, true, false, data)
+}
+
+// NextWriter returns a writer of a fragmented message, every Write is sent as a frame
+// and Close finishes the message. Compressed messages are sent on Close in one frame.
+func (c *Conn) NextWriter(messageType int) (io.WriteCloser, error) {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return nil, errors.New("websocket: fragmented messages must be text or binary")
+	}
+	return &messageWriter{conn: c, opcode: messageType}, nil
+}
+
+// WriteControl writes a ping, pong or close frame
+func (c *Conn) WriteControl(messageType int, data []byte) error {
+	if messageType != CloseMessage && messageType != PingMessage && messageType != PongMessage {
+		return errors.New("websocket: unknown control message type " + strconv.Itoa(messageType))
+	}
+	if len(data) > maxControlPayload {
+		return errors.New("websocket: control frame payload exceeds 125 bytes")
+	}
+	return c.writeFrame(messageType, true, false, data)
+}
+
+// WriteClose sends the close frame with the code and the reason,
+// data messages can not be written after that
+func (c *Conn) WriteClose(code int, text string) error {
+	payload := make([]byte, 2, 2+len(text))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, text...)
+	return c.WriteControl(CloseMessage, payload)
+}
+
+// Close sends the normal close frame if it is not sent yet and closes the network connection
+func (c *Conn) Close() error {
+	c.WriteClose(CloseNormalClosure, "")
+	return c.conn.Close()
+}
+
+func (c *Conn) writeFrame(opcode int, fin, rsv1 bool, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closeSent {
+		return &CloseError{Code: CloseAbnormalClosure, Text: "close frame already sent"}
+	}
+	if opcode == CloseMessage {
+		c.closeSent = true
+	}
+	frame := make([]byte, 2, 14+len(payload))
+	frame[0] = byte(opcode)
+	if fin {
+		frame[0] |= 0x80
+	}
+	if rsv1 {
+		frame[0] |= 0x40
+	}
+	length := len(payload)
+	switch {
+	case length <= 125:
+		frame[1] = byte(length)
+	case length <= 0xffff:
+		frame[1] = 126
+		frame = binary.BigEndian.AppendUint16(frame, uint16(length))
+	default:
+		frame[1] = 127
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+	if c.server {
+		frame = append(frame, payload...)
+	} else {
+		// clients mask every frame
+		frame[1] |= 0x80
+		var key [4]byte
+		rand.Read(key[:])
+		frame = append(frame, key[:]...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		maskBytes(key, frame[start:])
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+func maskBytes(key [4]byte, data []byte) {
+	for idx := range data {
+		data[idx] ^= key[idx&3]
+	}
+}
+
+// messageWriter writes a fragmented message
+type messageWriter struct {
+	conn    *Conn
+	opcode  int
+	started bool
+	closed  bool
+	buf     bytes.Buffer
+}
+
+func (w *messageWriter) Write(data []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("websocket: write to closed message writer")
+	}
+	if w.conn.compress {
+		return w.buf.Write(data)
+	}
+	if err := w.conn.writeFrame(w.frameOpcode(), false, false, data); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+func (w *messageWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if w.conn.compress {
+		return w.conn.WriteMessage(w.opcode, w.buf.Bytes())
+	}
+	return w.conn.writeFrame(w.frameOpcode(), true, false, nil)
+}
+
+// frameOpcode returns the message type for the first frame and continuation for others
+func (w *messageWriter) frameOpcode() int {
+	if w.started {
+		return continuationFrame
+	}
+	w.started = true
+	return w.opcode
+}
+
+var flateWriters = sync.Pool{
+	New: func() interface{} {
+		w, _ := flate.NewWriter(nil, flate.DefaultCompression)
+		return w
+	},
+}
+
+// deflate compresses the message without context takeover
+func deflate(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := flateWriters.Get().(*flate.Writer)
+	defer flateWriters.Put(w)
+	w.Reset(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte(deflateTail)), nil
+}
+
+// inflate decompresses the message with the limit of its size
+func inflate(data []byte, limit int64) ([]byte, error) {
+	// the final empty block finishes the stream
+	r := flate.NewReader(io.MultiReader(bytes.NewReader(data), strings.NewReader(deflateTail+"\x01\x00\x00\xff\xff")))
+	defer r.Close()
+	message, err := ioutil.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, &CloseError{Code: CloseInvalidPayload, Text: fmt.Sprint("invalid compressed data: ", err)}
+	}
+	if int64(len(message)) > limit {
+		return nil, &CloseError{Code: CloseMessageTooBig, Text: "message exceeds read limit"}
+	}
+	return message, nil
+}
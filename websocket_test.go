@@ -0,0 +1,211 @@
+package router
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testWebSocketServer serves echo connections which are closed by the client
+func testWebSocketServer(upgrader *Upgrader, errs chan<- error) *httptest.Server {
+	r := New()
+	r.Upgrader = upgrader
+	r.GET("/ws", func(c *Control) {
+		conn, err := c.Upgrade()
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				errs <- err
+				return
+			}
+			if err := conn.WriteMessage(messageType, data); err != nil {
+				errs <- err
+				return
+			}
+		}
+	})
+	return httptest.NewServer(r)
+}
+
+func TestWebSocketEcho(t *testing.T) {
+	errs := make(chan error, 1)
+	server := testWebSocketServer(&Upgrader{Subprotocols: []string{"chat", "json"}}, errs)
+	defer server.Close()
+
+	conn, resp, err := DialWebSocket(server.URL+"/ws", http.Header{"Sec-WebSocket-Protocol": {"json, chat"}})
+	if err != nil {
+		t.Fatal(err, resp)
+	}
+	if conn.Subprotocol() != "json" || conn.Compressed() {
+		t.Error("Expected", "json", "without compression, got", conn.Subprotocol(), conn.Compressed())
+	}
+	large := strings.Repeat("0123456789", 10000)
+	for _, message := range []string{"hello", "", large} {
+		if err := conn.WriteMessage(TextMessage, []byte(message)); err != nil {
+			t.Fatal(err)
+		}
+		messageType, data, err := conn.ReadMessage()
+		if err != nil || messageType != TextMessage || string(data) != message {
+			t.Error("Expected echo of", len(message), "bytes, got", messageType, len(data), err)
+		}
+	}
+	if err := conn.WriteMessage(BinaryMessage, []byte{0, 1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if messageType, data, _ := conn.ReadMessage(); messageType != BinaryMessage || len(data) != 3 {
+		t.Error("Expected binary echo, got", messageType, data)
+	}
+
+	// fragmented message
+	w, _ := conn.NextWriter(TextMessage)
+	w.Write([]byte("frag"))
+	w.Write([]byte("ment"))
+	w.Close()
+	if _, data, _ := conn.ReadMessage(); string(data) != "fragment" {
+		t.Error("Expected", "fragment", "got", string(data))
+	}
+
+	// ping is answered by pong
+	pong := make(chan string, 1)
+	conn.SetPongHandler(func(data []byte) { pong <- string(data) })
+	conn.WriteControl(PingMessage, []byte("ping"))
+	conn.WriteMessage(TextMessage, []byte("after ping"))
+	if _, data, _ := conn.ReadMessage(); string(data) != "after ping" {
+		t.Error("Expected", "after ping", "got", string(data))
+	}
+	if data := <-pong; data != "ping" {
+		t.Error("Expected", "ping", "got", data)
+	}
+
+	// close handshake
+	conn.WriteClose(4000, "bye")
+	if err, ok := (<-errs).(*CloseError); !ok || err.Code != 4000 || err.Text != "bye" {
+		t.Error("Expected server close error 4000, got", err)
+	}
+	if _, _, err := conn.ReadMessage(); err == nil || err.(*CloseError).Code != 4000 {
+		t.Error("Expected echo of close code 4000, got", err)
+	}
+	conn.Close()
+}
+
+func TestWebSocketCompression(t *testing.T) {
+	errs := make(chan error, 1)
+	server := testWebSocketServer(&Upgrader{Compression: true}, errs)
+	defer server.Close()
+
+	header := http.Header{"Sec-WebSocket-Extensions": {"permessage-deflate; client_max_window_bits"}}
+	conn, resp, err := DialWebSocket(server.URL+"/ws", header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !conn.Compressed() || !strings.Contains(resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate") {
+		t.Fatal("Expected permessage-deflate, got", resp.Header.Get("Sec-WebSocket-Extensions"))
+	}
+	message := strings.Repeat("compressible ", 1000)
+	for idx := 0; idx < 2; idx++ {
+		conn.WriteMessage(TextMessage, []byte(message))
+		if _, data, err := conn.ReadMessage(); err != nil || string(data) != message {
+			t.Error("Expected echo of compressed message, got", len(data), err)
+		}
+	}
+	conn.Close()
+	<-errs
+}
+
+func TestWebSocketReadLimit(t *testing.T) {
+	errs := make(chan error, 1)
+	server := testWebSocketServer(&Upgrader{ReadLimit: 10}, errs)
+	defer server.Close()
+
+	conn, _, err := DialWebSocket(server.URL+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.WriteMessage(TextMessage, []byte("more than ten bytes"))
+	if err, ok := (<-errs).(*CloseError); !ok || err.Code != CloseMessageTooBig {
+		t.Error("Expected", CloseMessageTooBig, "got", err)
+	}
+	if _, _, err := conn.ReadMessage(); err == nil || err.(*CloseError).Code != CloseMessageTooBig {
+		t.Error("Expected", CloseMessageTooBig, "got", err)
+	}
+}
+
+func TestWebSocketProtocolErrors(t *testing.T) {
+	errs := make(chan error, 1)
+	server := testWebSocketServer(nil, errs)
+	defer server.Close()
+
+	// clients must mask frames
+	conn, _, err := DialWebSocket(server.URL+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.server = true
+	conn.WriteMessage(TextMessage, []byte("unmasked"))
+	if err, ok := (<-errs).(*CloseError); !ok || err.Code != CloseProtocolError {
+		t.Error("Expected", CloseProtocolError, "got", err)
+	}
+	conn.Close()
+
+	// text messages must be valid UTF-8
+	conn, _, err = DialWebSocket(server.URL+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.WriteMessage(TextMessage, []byte{0xff, 0xfe})
+	if err, ok := (<-errs).(*CloseError); !ok || err.Code != CloseInvalidPayload {
+		t.Error("Expected", CloseInvalidPayload, "got", err)
+	}
+	conn.Close()
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	errs := make(chan error, 10)
+	server := testWebSocketServer(nil, errs)
+	defer server.Close()
+
+	_, resp, err := DialWebSocket(server.URL+"/ws", http.Header{"Origin": {"http://evil.example.com"}})
+	if err != ErrBadHandshake || resp.StatusCode != http.StatusForbidden {
+		t.Error("Expected", http.StatusForbidden, "got", err, resp)
+	}
+	conn, _, err := DialWebSocket(server.URL+"/ws", http.Header{"Origin": {server.URL}})
+	if err != nil {
+		t.Fatal("Expected same origin to be allowed, got", err)
+	}
+	conn.Close()
+	resp, err = http.Get(server.URL + "/ws")
+	if err != nil || resp.StatusCode != http.StatusBadRequest {
+		t.Error("Expected", http.StatusBadRequest, "got", err, resp.StatusCode)
+	}
+
+	// raw handshake with unsupported version
+	netConn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer netConn.Close()
+	netConn.SetDeadline(time.Now().Add(time.Second))
+	netConn.Write([]byte("GET /ws HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 8\r\n\r\n"))
+	resp, err = http.ReadResponse(bufio.NewReader(netConn), nil)
+	if err != nil || resp.StatusCode != http.StatusUpgradeRequired || resp.Header.Get("Sec-WebSocket-Version") != "13" {
+		t.Error("Expected", http.StatusUpgradeRequired, "got", err, resp)
+	}
+}
+
+func TestWebSocketAcceptKey(t *testing.T) {
+	// example from RFC 6455
+	if key := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); key != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Error("Expected", "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", "got", key)
+	}
+}
@@ -0,0 +1,578 @@
+// Copyright 2015 Igor Dolzhikov. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// node is an ordered representation of JSON data
+type node struct {
+	// scalar contains a raw JSON value of string, number, boolean or null
+	scalar json.RawMessage
+	// keys and values of an object in original order
+	keys   []string
+	values []*node
+	// items of an array
+	items   []*node
+	isMap   bool
+	isArray bool
+	// line is a number of line in source document
+	line int
+}
+
+// decodeNode reads JSON data with keeping of the keys order
+func decodeNode(data []byte) (*node, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	reader := &nodeReader{decoder: decoder, data: data, line: 1}
+	return reader.read()
+}
+
+// nodeReader reads JSON tokens with tracking of line numbers
+type nodeReader struct {
+	decoder *json.Decoder
+	data    []byte
+	offset  int
+	line    int
+}
+
+// lineAt returns a number of line of the first token after the offset,
+// offsets must not decrease between calls
+func (r *nodeReader) lineAt(offset int64) int {
+	end := int(offset)
+	for end < len(r.data) && strings.IndexByte(" \t\r\n,:", r.data[end]) >= 0 {
+		end++
+	}
+	for ; r.offset < end && r.offset < len(r.data); r.offset++ {
+		if r.data[r.offset] == '\n' {
+			r.line++
+		}
+	}
+	return r.line
+}
+
+func (r *nodeReader) read() (*node, error) {
+	line := r.lineAt(r.decoder.InputOffset())
+	token, err := r.decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch value := token.(type) {
+	case json.Delim:
+		n := &node{line: line}
+		if value == '{' {
+			n.isMap = true
+			for r.decoder.More() {
+				key, err := r.decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				child, err := r.read()
+				if err != nil {
+					return nil, err
+				}
+				n.keys = append(n.keys, key.(string))
+				n.values = append(n.values, child)
+			}
+		} else {
+			n.isArray = true
+			for r.decoder.More() {
+				child, err := r.read()
+				if err != nil {
+					return nil, err
+				}
+				n.items = append(n.items, child)
+			}
+		}
+		// closing delimiter
+		if _, err := r.decoder.Token(); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case string:
+		raw, err := json.Marshal(value)
+		return &node{scalar: raw, line: line}, err
+	case json.Number:
+		return &node{scalar: json.RawMessage(value.String()), line: line}, nil
+	case bool:
+		return &node{scalar: json.RawMessage(strconv.FormatBool(value)), line: line}, nil
+	default:
+		return &node{scalar: json.RawMessage("null"), line: line}, nil
+	}
+}
+
+// text returns a string representation of the scalar value
+func (n *node) text() string {
+	if len(n.scalar) > 0 && n.scalar[0] == '"' {
+		var str string
+		json.Unmarshal(n.scalar, &str)
+		return str
+	}
+	if string(n.scalar) == "null" {
+		return ""
+	}
+	return string(n.scalar)
+}
+
+// jsonToYAML converts JSON data into YAML document
+func jsonToYAML(data []byte) ([]byte, error) {
+	n, err := decodeNode(data)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	switch {
+	case n.isMap && len(n.keys) > 0, n.isArray && len(n.items) > 0:
+		writeYAML(&buf, n, "")
+	default:
+		buf.WriteString(yamlScalar(n))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+func writeYAML(buf *bytes.Buffer, n *node, indent string) {
+	if n.isMap {
+		for idx, key := range n.keys {
+			buf.WriteString(indent)
+			buf.WriteString(yamlString(key))
+			buf.WriteByte(':')
+			writeYAMLValue(buf, n.values[idx], indent)
+		}
+		return
+	}
+	for _, item := range n.items {
+		buf.WriteString(indent)
+		buf.WriteByte('-')
+		if item.isMap && len(item.keys) > 0 {
+			// first key follows the dash, the rest are aligned with it
+			var nested bytes.Buffer
+			writeYAML(&nested, item, indent+"  ")
+			buf.WriteByte(' ')
+			buf.Write(nested.Bytes()[len(indent)+2:])
+			continue
+		}
+		writeYAMLValue(buf, item, indent)
+	}
+}
+
+func writeYAMLValue(buf *bytes.Buffer, n *node, indent string) {
+	switch {
+	case n.isMap && len(n.keys) > 0, n.isArray && len(n.items) > 0:
+		buf.WriteByte('\n')
+		writeYAML(buf, n, indent+"  ")
+	default:
+		buf.WriteByte(' ')
+		buf.WriteString(yamlScalar(n))
+		buf.WriteByte('\n')
+	}
+}
+
+func yamlScalar(n *node) string {
+	switch {
+	case n.isMap:
+		return "{}"
+	case n.isArray:
+		return "[]"
+	case len(n.scalar) > 0 && n.scalar[0] == '"':
+		return yamlString(n.text())
+	}
+	return string(n.scalar)
+}
+
+// yamlString returns plain string if it is safe, or double-quoted string otherwise
+func yamlString(str string) string {
+	if isPlainYAML(str) {
+		return str
+	}
+	raw, _ := json.Marshal(str)
+	return string(raw)
+}
+
+func isPlainYAML(str string) bool {
+	if str == "" || str[0] == ' ' || str[len(str)-1] == ' ' {
+		return false
+	}
+	switch strings.ToLower(str) {
+	case "true", "false", "null", "yes", "no", "on", "off", "~":
+		return false
+	}
+	if c := str[0]; !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '/' || c == '_') {
+		return false
+	}
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' {
+			continue
+		}
+		// colon is allowed if it is not a key indicator
+		if c == ':' && i+1 < len(str) && str[i+1] != ' ' {
+			continue
+		}
+		if !strings.ContainsRune(" _./-(){}", rune(c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// yamlNumber matches plain scalars which are numbers
+var yamlNumber = regexp.MustCompile(`^[-+]?(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?$`)
+
+// yamlLine is a significant line of YAML document
+type yamlLine struct {
+	number int
+	indent int
+	text   string
+}
+
+// yamlParser decodes a subset of YAML: block mappings and sequences,
+// flow sequences of scalars, plain and quoted scalars and comments
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// YAMLError reports a problem in YAML document
+type YAMLError struct {
+	Line    int
+	Message string
+}
+
+func (e *YAMLError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// decodeYAML reads YAML document into ordered representation
+func decodeYAML(data []byte) (*node, error) {
+	p := new(yamlParser)
+	for idx, text := range strings.Split(string(data), "\n") {
+		text = strings.TrimRight(stripComment(text), " \r")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, &YAMLError{Line: idx + 1, Message: "tabs are not allowed for indentation"}
+		}
+		p.lines = append(p.lines, yamlLine{number: idx + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	if len(p.lines) == 0 {
+		return &node{scalar: json.RawMessage("null")}, nil
+	}
+	n, err := p.block(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, &YAMLError{Line: p.lines[p.pos].number, Message: "unexpected indentation"}
+	}
+	return n, nil
+}
+
+// stripComment removes a comment which is not a part of quoted string
+func stripComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || text[i-1] == ' '):
+			return text[:i]
+		}
+	}
+	return text
+}
+
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// block reads a mapping or a sequence with the given indentation
+func (p *yamlParser) block(indent int) (*node, error) {
+	line := p.lines[p.pos]
+	if isSequenceItem(line.text) {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+func (p *yamlParser) sequence(indent int) (*node, error) {
+	n := &node{isArray: true, line: p.lines[p.pos].number}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent || line.indent == indent && !isSequenceItem(line.text) {
+			break
+		}
+		if line.indent > indent {
+			return nil, &YAMLError{Line: line.number, Message: "unexpected indentation"}
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		if rest == "" {
+			p.pos++
+			item, err := p.nested(indent, line.number)
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, item)
+			continue
+		}
+		// item content is treated as a line with deeper indentation
+		p.lines[p.pos] = yamlLine{
+			number: line.number,
+			indent: line.indent + len(line.text) - len(rest),
+			text:   rest,
+		}
+		if isSequenceItem(rest) || isMappingEntry(rest) {
+			item, err := p.block(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, item)
+			continue
+		}
+		item, err := yamlValue(rest, line.number)
+		if err != nil {
+			return nil, err
+		}
+		p.pos++
+		n.items = append(n.items, item)
+	}
+	return n, nil
+}
+
+func (p *yamlParser) mapping(indent int) (*node, error) {
+	n := &node{isMap: true, line: p.lines[p.pos].number}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent || isSequenceItem(line.text) {
+			return nil, &YAMLError{Line: line.number, Message: "unexpected indentation"}
+		}
+		key, rest, err := splitMappingEntry(line.text, line.number)
+		if err != nil {
+			return nil, err
+		}
+		for _, existing := range n.keys {
+			if existing == key {
+				return nil, &YAMLError{Line: line.number, Message: fmt.Sprintf("duplicate key %q", key)}
+			}
+		}
+		p.pos++
+		var value *node
+		if rest == "" {
+			// a sequence is allowed on the same indentation as a key
+			if p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isSequenceItem(p.lines[p.pos].text) {
+				value, err = p.sequence(indent)
+			} else {
+				value, err = p.nested(indent, line.number)
+			}
+		} else {
+			value, err = yamlValue(rest, line.number)
+		}
+		if err != nil {
+			return nil, err
+		}
+		n.keys = append(n.keys, key)
+		n.values = append(n.values, value)
+	}
+	return n, nil
+}
+
+// nested reads a block which is deeper than indent, or null if there is no such block
+func (p *yamlParser) nested(indent, number int) (*node, error) {
+	if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+		return p.block(p.lines[p.pos].indent)
+	}
+	return &node{scalar: json.RawMessage("null"), line: number}, nil
+}
+
+func isMappingEntry(text string) bool {
+	_, _, err := splitMappingEntry(text, 0)
+	return err == nil
+}
+
+// splitMappingEntry splits "key: value" line into key and value
+func splitMappingEntry(text string, number int) (string, string, error) {
+	var key, rest string
+	if text[0] == '"' || text[0] == '\'' {
+		end := closingQuote(text)
+		if end < 0 {
+			return "", "", &YAMLError{Line: number, Message: "unterminated quoted key"}
+		}
+		unquoted, err := yamlValue(text[:end+1], number)
+		if err != nil {
+			return "", "", err
+		}
+		key, rest = unquoted.text(), text[end+1:]
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", &YAMLError{Line: number, Message: "expected ':' after key"}
+		}
+		rest = rest[1:]
+	} else {
+		idx := strings.Index(text, ": ")
+		if idx < 0 && strings.HasSuffix(text, ":") {
+			idx = len(text) - 1
+		}
+		if idx <= 0 {
+			return "", "", &YAMLError{Line: number, Message: "expected 'key: value'"}
+		}
+		key, rest = text[:idx], text[idx+1:]
+	}
+	if rest != "" && rest[0] != ' ' {
+		return "", "", &YAMLError{Line: number, Message: "expected space after ':'"}
+	}
+	return key, strings.TrimLeft(rest, " "), nil
+}
+
+// closingQuote returns position of a quote which closes the string
+func closingQuote(text string) int {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case text[i] == '\\' && quote == '"':
+			i++
+		case text[i] == quote && quote == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// yamlValue decodes a scalar or a flow sequence of scalars
+func yamlValue(text string, number int) (*node, error) {
+	switch {
+	case text == "[]":
+		return &node{isArray: true, line: number}, nil
+	case text == "{}":
+		return &node{isMap: true, line: number}, nil
+	case text[0] == '[':
+		if !strings.HasSuffix(text, "]") {
+			return nil, &YAMLError{Line: number, Message: "unterminated flow sequence"}
+		}
+		n := &node{isArray: true, line: number}
+		for _, item := range splitFlow(text[1 : len(text)-1]) {
+			if item = strings.TrimSpace(item); item == "" {
+				return nil, &YAMLError{Line: number, Message: "empty item in flow sequence"}
+			}
+			value, err := yamlValue(item, number)
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, value)
+		}
+		return n, nil
+	case text[0] == '{' || text[0] == '|' || text[0] == '>' || text[0] == '&' || text[0] == '*':
+		return nil, &YAMLError{Line: number, Message: fmt.Sprintf("unsupported YAML syntax %q", text)}
+	case text[0] == '"':
+		if closingQuote(text) != len(text)-1 {
+			return nil, &YAMLError{Line: number, Message: "invalid quoted string"}
+		}
+		str, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, &YAMLError{Line: number, Message: "invalid quoted string"}
+		}
+		raw, _ := json.Marshal(str)
+		return &node{scalar: raw, line: number}, nil
+	case text[0] == '\'':
+		if closingQuote(text) != len(text)-1 {
+			return nil, &YAMLError{Line: number, Message: "invalid quoted string"}
+		}
+		raw, _ := json.Marshal(strings.Replace(text[1:len(text)-1], "''", "'", -1))
+		return &node{scalar: raw, line: number}, nil
+	}
+	switch text {
+	case "null", "Null", "NULL", "~":
+		return &node{scalar: json.RawMessage("null"), line: number}, nil
+	case "true", "True", "TRUE":
+		return &node{scalar: json.RawMessage("true"), line: number}, nil
+	case "false", "False", "FALSE":
+		return &node{scalar: json.RawMessage("false"), line: number}, nil
+	}
+	if yamlNumber.MatchString(text) {
+		if raw := json.RawMessage(strings.TrimPrefix(text, "+")); json.Valid(raw) {
+			return &node{scalar: raw, line: number}, nil
+		}
+	}
+	raw, _ := json.Marshal(text)
+	return &node{scalar: raw, line: number}, nil
+}
+
+// splitFlow splits items of flow sequence by commas outside of quotes
+func splitFlow(text string) []string {
+	var items []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			items = append(items, text[start:i])
+			start = i + 1
+		}
+	}
+	if strings.TrimSpace(text) != "" {
+		items = append(items, text[start:])
+	}
+	return items
+}
+
+// json encodes the node into JSON data
+func (n *node) json() []byte {
+	var buf bytes.Buffer
+	n.writeJSON(&buf)
+	return buf.Bytes()
+}
+
+func (n *node) writeJSON(buf *bytes.Buffer) {
+	switch {
+	case n.isMap:
+		buf.WriteByte('{')
+		for idx, key := range n.keys {
+			if idx > 0 {
+				buf.WriteByte(',')
+			}
+			raw, _ := json.Marshal(key)
+			buf.Write(raw)
+			buf.WriteByte(':')
+			n.values[idx].writeJSON(buf)
+		}
+		buf.WriteByte('}')
+	case n.isArray:
+		buf.WriteByte('[')
+		for idx, item := range n.items {
+			if idx > 0 {
+				buf.WriteByte(',')
+			}
+			item.writeJSON(buf)
+		}
+		buf.WriteByte(']')
+	default:
+		buf.Write(n.scalar)
+	}
+}